@@ -0,0 +1,119 @@
+package anonymize
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+)
+
+// AddrAnonymizer is the netip equivalent of IPAnonymizer. Because netip.Addr is
+// a value type, its methods never modify their arguments. The "none" and
+// "netblock" implementations do not allocate. Zones are not preserved by
+// anonymization.
+type AddrAnonymizer interface {
+	// Addr returns the anonymized form of addr.
+	Addr(addr netip.Addr) netip.Addr
+	// ContainsAddr determines whether the anonymized form of dst would also be
+	// the anonymized form of addr.
+	ContainsAddr(dst, addr netip.Addr) bool
+}
+
+// AsAddrAnonymizer returns an AddrAnonymizer for the passed-in IPAnonymizer.
+// Every anonymizer returned by New and NewFromConfig implements AddrAnonymizer
+// directly. Other implementations are adapted by passing copies of addresses
+// to their net.IP-based methods.
+func AsAddrAnonymizer(a IPAnonymizer) AddrAnonymizer {
+	if aa, ok := a.(AddrAnonymizer); ok {
+		return aa
+	}
+	return ipAnonymizerAdapter{a}
+}
+
+// ipAnonymizerAdapter adapts an IPAnonymizer to the AddrAnonymizer interface.
+type ipAnonymizerAdapter struct {
+	IPAnonymizer
+}
+
+func (a ipAnonymizerAdapter) Addr(addr netip.Addr) netip.Addr {
+	if !addr.IsValid() {
+		return addr
+	}
+	ip := net.IP(addr.AsSlice())
+	a.IP(ip)
+	r, _ := netip.AddrFromSlice(ip)
+	return r
+}
+
+func (a ipAnonymizerAdapter) ContainsAddr(dst, addr netip.Addr) bool {
+	if !dst.IsValid() || !addr.IsValid() {
+		return false
+	}
+	return a.Contains(dst.AsSlice(), addr.AsSlice())
+}
+
+// writeAddr copies addr into ip, preserving the length of ip.
+func writeAddr(ip net.IP, addr netip.Addr) {
+	if len(ip) == net.IPv4len {
+		b := addr.Unmap().As4()
+		copy(ip, b[:])
+		return
+	}
+	b := addr.As16()
+	copy(ip, b[:])
+}
+
+// anonymizedCopy returns an anonymized copy of ip, leaving ip unmodified.
+func anonymizedCopy(a IPAnonymizer, ip net.IP) net.IP {
+	if ip == nil {
+		return nil
+	}
+	c := slices.Clone(ip)
+	a.IP(c)
+	return c
+}
+
+// AddrPort returns the anonymized form of addr. The port is preserved.
+func AddrPort(a IPAnonymizer, addr netip.AddrPort) netip.AddrPort {
+	return netip.AddrPortFrom(AsAddrAnonymizer(a).Addr(addr.Addr()), addr.Port())
+}
+
+// TCPAddr returns an anonymized copy of addr. addr itself is not modified.
+func TCPAddr(a IPAnonymizer, addr *net.TCPAddr) *net.TCPAddr {
+	if addr == nil {
+		return nil
+	}
+	return &net.TCPAddr{IP: anonymizedCopy(a, addr.IP), Port: addr.Port, Zone: addr.Zone}
+}
+
+// UDPAddr returns an anonymized copy of addr. addr itself is not modified.
+func UDPAddr(a IPAnonymizer, addr *net.UDPAddr) *net.UDPAddr {
+	if addr == nil {
+		return nil
+	}
+	return &net.UDPAddr{IP: anonymizedCopy(a, addr.IP), Port: addr.Port, Zone: addr.Zone}
+}
+
+// NetAddr returns an anonymized copy of addr. The address types of the net
+// package that contain an IP (*net.TCPAddr, *net.UDPAddr, *net.IPAddr and
+// *net.IPNet) are anonymized. All other types are returned unchanged, as they
+// contain no IP address. addr itself is never modified.
+func NetAddr(a IPAnonymizer, addr net.Addr) net.Addr {
+	switch v := addr.(type) {
+	case *net.TCPAddr:
+		return TCPAddr(a, v)
+	case *net.UDPAddr:
+		return UDPAddr(a, v)
+	case *net.IPAddr:
+		if v == nil {
+			return v
+		}
+		return &net.IPAddr{IP: anonymizedCopy(a, v.IP), Zone: v.Zone}
+	case *net.IPNet:
+		if v == nil {
+			return v
+		}
+		return &net.IPNet{IP: anonymizedCopy(a, v.IP), Mask: slices.Clone(v.Mask)}
+	default:
+		return addr
+	}
+}
@@ -0,0 +1,166 @@
+package anonymize_test
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/m-lab/go/anonymize"
+	"github.com/m-lab/go/rtx"
+)
+
+func TestAddrAnonymizer_Addr(t *testing.T) {
+	ignored := []netip.Prefix{netip.MustParsePrefix("10.99.0.0/16")}
+	netblock, err := anonymize.NewFromConfig(anonymize.Config{
+		Method: anonymize.Netblock, IPv4Prefix: 24, IPv6Prefix: 48, IgnoredPrefixes: ignored,
+		LocalAddrs: anonymize.StaticLocalAddrs(netip.MustParseAddr("127.0.0.1")),
+	})
+	rtx.Must(err, "Could not create anonymizer")
+	tests := []struct {
+		name string
+		anon anonymize.IPAnonymizer
+		addr netip.Addr
+		want netip.Addr
+	}{
+		{"netblock-v4", netblock, netip.MustParseAddr("10.1.2.3"), netip.MustParseAddr("10.1.2.0")},
+		{"netblock-v4in6", netblock, netip.MustParseAddr("::ffff:10.1.2.3"), netip.MustParseAddr("::ffff:10.1.2.0")},
+		{"netblock-v6", netblock, netip.MustParseAddr("2001:db8:1:2::3"), netip.MustParseAddr("2001:db8:1::")},
+		{"netblock-v6-zone", netblock, netip.MustParseAddr("fe80::1%eth0"), netip.MustParseAddr("fe80::")},
+		{"netblock-ignored-ip", netblock, netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("127.0.0.1")},
+		{"netblock-ignored-ip-v4in6", netblock, netip.MustParseAddr("::ffff:127.0.0.1"), netip.MustParseAddr("::ffff:127.0.0.1")},
+		{"netblock-ignored-prefix", netblock, netip.MustParseAddr("10.99.2.3"), netip.MustParseAddr("10.99.2.3")},
+		{"netblock-invalid", netblock, netip.Addr{}, netip.Addr{}},
+		{"none", anonymize.New(anonymize.None), netip.MustParseAddr("10.1.2.3"), netip.MustParseAddr("10.1.2.3")},
+		{"adapter", fakeAnonymizer{}, netip.MustParseAddr("10.1.2.3"), netip.MustParseAddr("0.1.2.3")},
+		{"adapter-invalid", fakeAnonymizer{}, netip.Addr{}, netip.Addr{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := anonymize.AsAddrAnonymizer(tt.anon).Addr(tt.addr); got != tt.want {
+				t.Errorf("Addr(%v) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddrAnonymizer_ContainsAddr(t *testing.T) {
+	netblock := anonymize.AsAddrAnonymizer(newNetblock("127.0.0.1"))
+	none := anonymize.AsAddrAnonymizer(anonymize.New(anonymize.None))
+	adapter := anonymize.AsAddrAnonymizer(fakeAnonymizer{})
+	tests := []struct {
+		name string
+		anon anonymize.AddrAnonymizer
+		dst  string
+		addr string
+		want bool
+	}{
+		{"netblock-v4", netblock, "192.168.0.1", "192.168.0.2", true},
+		{"netblock-v4-mapped", netblock, "192.168.0.1", "::ffff:192.168.0.2", true},
+		{"netblock-v4-other", netblock, "192.168.0.1", "192.168.1.2", false},
+		{"netblock-v6", netblock, "fd12:3456:789a:1::1", "fd12:3456:789a:2::2", true},
+		{"netblock-v6-other", netblock, "fd12:3456:789a:1::1", "fd12:3456:789b:1::1", false},
+		{"netblock-mixed", netblock, "fd12:3456:789a:1::1", "192.168.1.2", false},
+		{"netblock-ignored", netblock, "127.0.0.1", "127.0.0.1", false},
+		{"netblock-invalid", netblock, "", "127.0.0.1", false},
+		{"none-equal", none, "192.168.0.1", "192.168.0.1", true},
+		{"none-different", none, "192.168.0.1", "192.168.0.2", false},
+		{"adapter", adapter, "192.168.0.1", "192.168.0.2", true},
+		{"adapter-invalid", adapter, "192.168.0.1", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst, addr netip.Addr
+			if tt.dst != "" {
+				dst = netip.MustParseAddr(tt.dst)
+			}
+			if tt.addr != "" {
+				addr = netip.MustParseAddr(tt.addr)
+			}
+			if got := tt.anon.ContainsAddr(dst, addr); got != tt.want {
+				t.Errorf("ContainsAddr(%v, %v) = %v, want %v", dst, addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddrAnonymizer_NoAllocs(t *testing.T) {
+	anon := anonymize.AsAddrAnonymizer(newNetblock("127.0.0.1"))
+	v4 := netip.MustParseAddr("10.1.2.3")
+	v6 := netip.MustParseAddr("2001:db8:1:2::3")
+	allocs := testing.AllocsPerRun(100, func() {
+		anon.Addr(v4)
+		anon.Addr(v6)
+		anon.ContainsAddr(v4, v4)
+	})
+	if allocs != 0 {
+		t.Errorf("netblock anonymization allocated %v times, want 0", allocs)
+	}
+}
+
+func TestNetAddrHelpers(t *testing.T) {
+	anon := newNetblock()
+
+	tcp := &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 80}
+	udp := &net.UDPAddr{IP: net.ParseIP("10.1.2.3"), Port: 53}
+	ipaddr := &net.IPAddr{IP: net.ParseIP("10.1.2.3")}
+	ipnet := &net.IPNet{IP: net.ParseIP("10.1.2.3"), Mask: net.CIDRMask(32, 32)}
+	unix := &net.UnixAddr{Name: "/tmp/sock", Net: "unix"}
+	tests := []struct {
+		name string
+		addr net.Addr
+		want string
+	}{
+		{"tcp", tcp, "10.1.2.0:80"},
+		{"udp", udp, "10.1.2.0:53"},
+		{"ipaddr", ipaddr, "10.1.2.0"},
+		{"ipnet", ipnet, "10.1.2.0/32"},
+		{"unix", unix, "/tmp/sock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := anonymize.NetAddr(anon, tt.addr); got.String() != tt.want {
+				t.Errorf("NetAddr() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	// The originals must not have been modified.
+	for _, a := range []net.Addr{tcp, udp, ipaddr} {
+		if a.String() != "10.1.2.3:80" && a.String() != "10.1.2.3:53" && a.String() != "10.1.2.3" {
+			t.Errorf("NetAddr() modified its argument: %v", a)
+		}
+	}
+	if ipnet.IP.String() != "10.1.2.3" {
+		t.Errorf("NetAddr() modified its argument: %v", ipnet)
+	}
+
+	// Nil pointers are returned as nil.
+	if anonymize.TCPAddr(anon, nil) != nil || anonymize.UDPAddr(anon, nil) != nil {
+		t.Error("TCPAddr() and UDPAddr() should return nil for nil input")
+	}
+	if got := anonymize.NetAddr(anon, (*net.IPAddr)(nil)); got.(*net.IPAddr) != nil {
+		t.Error("NetAddr() should return nil for a nil *net.IPAddr")
+	}
+	if got := anonymize.NetAddr(anon, (*net.IPNet)(nil)); got.(*net.IPNet) != nil {
+		t.Error("NetAddr() should return nil for a nil *net.IPNet")
+	}
+	if got := anonymize.TCPAddr(anon, &net.TCPAddr{Port: 1}); got.IP != nil {
+		t.Errorf("TCPAddr() with a nil IP = %v, want a nil IP", got)
+	}
+
+	ap := anonymize.AddrPort(anon, netip.MustParseAddrPort("[2001:db8:1:2::3]:443"))
+	if ap.String() != "[2001:db8:1::]:443" {
+		t.Errorf("AddrPort() = %v, want [2001:db8:1::]:443", ap)
+	}
+}
+
+// fakeAnonymizer is an IPAnonymizer that only implements the net.IP methods.
+// It zeroes the first byte of the address.
+type fakeAnonymizer struct{}
+
+func (fakeAnonymizer) IP(ip net.IP) {
+	ip[len(ip)-4] = 0
+}
+
+func (fakeAnonymizer) Contains(dst, ip net.IP) bool {
+	return dst.To4()[1] == ip.To4()[1]
+}
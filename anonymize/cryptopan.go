@@ -0,0 +1,137 @@
+package anonymize
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+)
+
+// CryptoPAnKeySize is the size in bytes of the secret key used by the
+// CryptoPAn anonymization method. The first half of the key is the AES key,
+// and the second half is encrypted to produce the pad.
+const CryptoPAnKeySize = 32
+
+// cryptoPAnAnonymizer implements the prefix-preserving pseudonymization scheme
+// described in "Prefix-Preserving IP Address Anonymization" by Xu, Fan, Ammar
+// and Moon (2002). Two addresses that share a k-bit prefix will have
+// pseudonyms that share a k-bit prefix, and the mapping is deterministic for a
+// given key, so records from the same host can be joined across datasets
+// anonymized with the same key.
+type cryptoPAnAnonymizer struct {
+	block   cipher.Block
+	pad     [aes.BlockSize]byte
+	ignored ignoreList
+}
+
+func newCryptoPAnAnonymizer(key []byte, ignored ignoreList) (*cryptoPAnAnonymizer, error) {
+	if len(key) != CryptoPAnKeySize {
+		return nil, fmt.Errorf("CryptoPAn key must be %d bytes, not %d", CryptoPAnKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key[:aes.BlockSize])
+	if err != nil {
+		return nil, err
+	}
+	c := &cryptoPAnAnonymizer{
+		block:   block,
+		ignored: ignored,
+	}
+	block.Encrypt(c.pad[:], key[aes.BlockSize:])
+	return c, nil
+}
+
+// pseudonymize replaces the bytes of addr (either 4 or 16 bytes long) with
+// their pseudonym. For every bit position i, the i-bit prefix of the original
+// address is combined with the pad and encrypted, and the first bit of the
+// ciphertext determines whether bit i of the address is flipped.
+func (c *cryptoPAnAnonymizer) pseudonymize(addr []byte) {
+	var in, out [aes.BlockSize]byte
+	var otp [net.IPv6len]byte
+	for pos := 0; pos < len(addr)*8; pos++ {
+		in = c.pad
+		full := pos / 8
+		copy(in[:full], addr[:full])
+		if rem := pos % 8; rem != 0 {
+			mask := byte(0xff) << (8 - rem)
+			in[full] = (addr[full] & mask) | (c.pad[full] &^ mask)
+		}
+		c.block.Encrypt(out[:], in[:])
+		otp[pos/8] |= (out[0] >> 7) << (7 - pos%8)
+	}
+	for i := range addr {
+		addr[i] ^= otp[i]
+	}
+}
+
+func (c *cryptoPAnAnonymizer) IP(ip net.IP) {
+	if ip == nil {
+		return
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		log.Println("The passed in IP address was neither a v4 nor a v6 address:", ip)
+		return
+	}
+	writeAddr(ip, c.Addr(addr))
+}
+
+// Contains determines whether the pseudonym of dst is the pseudonym of ip.
+// Because every address has its own pseudonym, this is true only when the two
+// addresses are equal.
+func (c *cryptoPAnAnonymizer) Contains(dst, ip net.IP) bool {
+	d, ok := netip.AddrFromSlice(dst)
+	if !ok {
+		return false
+	}
+	a, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	return c.ContainsAddr(d, a)
+}
+
+// Addr returns the pseudonym of addr. v4-in-v6 addresses are pseudonymized as
+// v4 addresses and returned in the same representation they were passed in.
+func (c *cryptoPAnAnonymizer) Addr(addr netip.Addr) netip.Addr {
+	if !addr.IsValid() || c.ignored.contains(addr) {
+		return addr
+	}
+	if u := addr.Unmap(); u.Is4() {
+		b := u.As4()
+		c.pseudonymize(b[:])
+		if addr.Is4In6() {
+			return netip.AddrFrom16(netip.AddrFrom4(b).As16())
+		}
+		return netip.AddrFrom4(b)
+	}
+	b := addr.As16()
+	c.pseudonymize(b[:])
+	return netip.AddrFrom16(b)
+}
+
+// ContainsAddr is the netip.Addr equivalent of Contains.
+func (c *cryptoPAnAnonymizer) ContainsAddr(dst, addr netip.Addr) bool {
+	if !dst.IsValid() || !addr.IsValid() {
+		return false
+	}
+	if c.ignored.contains(dst) {
+		return false
+	}
+	return dst.Unmap().WithZone("") == addr.Unmap().WithZone("")
+}
+
+// decodeKey interprets the contents of a key file. Keys may be stored either as
+// raw bytes or hex-encoded, optionally followed by a newline.
+func decodeKey(b []byte) []byte {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == hex.EncodedLen(CryptoPAnKeySize) {
+		if k, err := hex.DecodeString(string(trimmed)); err == nil {
+			return k
+		}
+	}
+	return b
+}
@@ -0,0 +1,164 @@
+package anonymize_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-lab/go/anonymize"
+	"github.com/m-lab/go/flagx"
+	"github.com/m-lab/go/rtx"
+)
+
+// The key and address pairs below come from the sample data distributed with
+// the reference Crypto-PAn implementation.
+var cryptoPAnKey = []byte{
+	21, 34, 23, 141, 51, 164, 207, 128, 19, 10, 91, 22, 73, 144, 125, 16,
+	216, 152, 143, 131, 121, 121, 101, 39, 98, 87, 76, 45, 42, 132, 34, 2,
+}
+
+func TestCryptoPAnReferenceVectors(t *testing.T) {
+	anon, err := anonymize.NewFromConfig(anonymize.Config{Method: anonymize.CryptoPAn, Key: cryptoPAnKey})
+	rtx.Must(err, "Could not create anonymizer")
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"128.11.68.132", "135.242.180.132"},
+		{"129.118.74.4", "134.136.186.123"},
+		{"130.132.252.244", "133.68.164.234"},
+		{"141.223.7.43", "141.167.8.160"},
+		{"141.233.145.108", "141.129.237.235"},
+		{"156.29.3.236", "147.225.12.42"},
+		{"165.247.96.84", "162.9.99.234"},
+		{"166.107.77.190", "160.132.178.185"},
+		{"192.102.249.13", "252.138.62.131"},
+		{"192.215.32.125", "252.43.47.189"},
+		{"192.233.80.103", "252.25.108.8"},
+		{"192.41.57.43", "252.222.221.184"},
+		{"193.150.244.223", "253.169.52.216"},
+		{"195.205.63.100", "255.186.223.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			// Both the 16-byte and 4-byte representations should work.
+			ip := net.ParseIP(tt.ip)
+			anon.IP(ip)
+			if ip.String() != tt.want {
+				t.Errorf("IP() = %q, want %q", ip, tt.want)
+			}
+			ip4 := net.ParseIP(tt.ip).To4()
+			anon.IP(ip4)
+			if ip4.String() != tt.want {
+				t.Errorf("IP() = %q, want %q", ip4, tt.want)
+			}
+		})
+	}
+}
+
+func commonPrefixLen(a, b net.IP) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			n := i * 8
+			for x&0x80 == 0 {
+				n++
+				x <<= 1
+			}
+			return n
+		}
+	}
+	return len(a) * 8
+}
+
+func TestCryptoPAnPreservesPrefixes(t *testing.T) {
+	anon, err := anonymize.NewFromConfig(anonymize.Config{Method: anonymize.CryptoPAn, Key: cryptoPAnKey})
+	rtx.Must(err, "Could not create anonymizer")
+	for _, addrs := range [][]string{
+		{"2001:db8::1", "2001:db8::2", "2001:db8:1::1", "2001:db9::1", "fe80::1"},
+		{"10.0.0.1", "10.0.0.2", "10.0.1.1", "10.128.0.1", "11.0.0.1"},
+	} {
+		for _, a := range addrs {
+			for _, b := range addrs {
+				ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+				want := commonPrefixLen(ipA, ipB)
+				anon.IP(ipA)
+				anon.IP(ipB)
+				if got := commonPrefixLen(ipA, ipB); got != want {
+					t.Errorf("common prefix of pseudonyms of %s and %s is %d bits, want %d", a, b, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCryptoPAnContainsAndIgnored(t *testing.T) {
+	ignored, err := anonymize.ParseCIDRs("192.168.0.0/16")
+	rtx.Must(err, "Could not parse CIDRs")
+	anon, err := anonymize.NewFromConfig(anonymize.Config{
+		Method:      anonymize.CryptoPAn,
+		Key:         cryptoPAnKey,
+		IgnoredNets: ignored,
+		LocalAddrs:  anonymize.StaticLocalAddrs(netip.MustParseAddr("127.0.0.1")),
+	})
+	rtx.Must(err, "Could not create anonymizer")
+
+	anon.IP(nil)                  // No crash = success
+	anon.IP(net.IP([]byte{1, 2})) // No crash = success
+
+	for _, s := range []string{"127.0.0.1", "192.168.4.5"} {
+		ip := net.ParseIP(s)
+		anon.IP(ip)
+		if ip.String() != s {
+			t.Errorf("IP(%s) = %s, but ignored addresses should not change", s, ip)
+		}
+	}
+	tests := []struct {
+		name string
+		dst  net.IP
+		ip   net.IP
+		want bool
+	}{
+		{"equal", net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.1"), true},
+		{"same-netblock", net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), false},
+		{"ignored", net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.1"), false},
+		{"nil-dst", nil, net.ParseIP("10.0.0.1"), false},
+		{"nil-ip", net.ParseIP("10.0.0.1"), nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := anon.Contains(tt.dst, tt.ip); got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCryptoPAnBadKey(t *testing.T) {
+	_, err := anonymize.NewFromConfig(anonymize.Config{Method: anonymize.CryptoPAn, Key: []byte("too short")})
+	if err == nil {
+		t.Error("NewFromConfig() should have failed with a short key")
+	}
+}
+
+func TestCryptoPAnKeyFileFlag(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{
+		"raw": cryptoPAnKey,
+		"hex": []byte(hex.EncodeToString(cryptoPAnKey) + "\n"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			fname := filepath.Join(dir, name)
+			rtx.Must(os.WriteFile(fname, content, 0600), "Could not write key file")
+			rtx.Must(anonymize.KeyFileFlag.Set(fname), "Could not set key file flag")
+			defer func() { anonymize.KeyFileFlag = flagx.File{} }()
+			c := anonymize.ConfigFromFlags()
+			if !bytes.Equal(c.Key, cryptoPAnKey) {
+				t.Errorf("ConfigFromFlags().Key = %v, want %v", c.Key, cryptoPAnKey)
+			}
+		})
+	}
+}
@@ -6,13 +6,32 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/m-lab/go/flagx"
+)
+
+// Default prefix lengths used by the netblock anonymizer when no others are
+// configured.
+const (
+	DefaultIPv4Prefix = 24
+	DefaultIPv6Prefix = 48
 )
 
 var (
 	// Netblock causes IPv4 addresses to be anonymized up to the
-	// /24 level and IPv6 addresses to the /64 level.
+	// /24 level and IPv6 addresses to the /48 level, unless other prefix
+	// lengths are configured.
 	Netblock = Method("netblock")
 
+	// CryptoPAn causes addresses to be replaced by keyed, prefix-preserving
+	// pseudonyms. The same address always maps to the same pseudonym for a
+	// given key, and addresses sharing a prefix map to pseudonyms sharing a
+	// prefix of the same length. The key is read from the file named by the
+	// `--anonymize.key-file` flag.
+	CryptoPAn = Method("cryptopan")
+
 	// None performs no anonymization. By creating an anonymizer that performs
 	// no anonymization, we make it possible to always have the anonymizer code
 	// path be used, whether anonymization is actually needed or not, preventing
@@ -27,33 +46,15 @@ var (
 	// anonymization.
 	IPAnonymizationFlag = None
 
-	// IgnoredIPs is a set of IPs that should be ignored and not anonymized. By
-	// default it is the set of local IP addresses. This set should be small, so
-	// it is represented as an array because net.IP objects can't be used as map
-	// keys.
-	//
-	// If runtime profiling indicates this causes too much of a slowdown in
-	// practice, then the other design that is "near at hand" with Go primitives
-	// is to use map[string]struct{} where the string is the IP converted to a
-	// string. If that design also causes too much of a slowdown in practice,
-	// then we will have to fall back on our algorithms knowledge and build a
-	// clever data structure. The 3 main options are:
-	//  1. a bloom filter in front of a set object,
-	//  2. a 256-ary tree using each successive byte of the IP for each
-	//     successive level, or
-	//  3. a map[int32]struct{} for v4 addresses and a
-	//     map[int64](map[int64]struct{}) for v6 addresses.
-	// Likely the last option will be the fastest; ceteris paribus, native ints
-	// and language builtins tend to have the best performance.
-	//
-	// Big-O analysis doesn't buy us much here. There are a small number of
-	// local IPs and each one is of length 4 or 16. This means that taking the
-	// limit as N goes to infinity doesn't tell us much, because all of the
-	// quantities we might call "N" are 16 or less in all realistic scenarios,
-	// and 16 is a poor approximation of infinity. Therefore, any claims about
-	// relative speed of implementation need to be backed up by experimental
-	// evidence.
-	IgnoredIPs = []net.IP{}
+	// IPv4PrefixFlag and IPv6PrefixFlag hold the prefix lengths to which
+	// netblock anonymization truncates addresses. Like IPAnonymizationFlag,
+	// their values should be fixed for the duration of a program.
+	IPv4PrefixFlag = DefaultIPv4Prefix
+	IPv6PrefixFlag = DefaultIPv6Prefix
+
+	// KeyFileFlag holds the secret key used by the CryptoPAn method. The file
+	// should contain 32 bytes, either raw or hex-encoded.
+	KeyFileFlag flagx.File
 
 	// An injected log.Fatal to aid in testing.
 	logFatalf = log.Fatalf
@@ -75,6 +76,8 @@ func (m *Method) Set(s string) error {
 	switch Method(s) {
 	case Netblock:
 		*m = Netblock
+	case CryptoPAn:
+		*m = CryptoPAn
 	case None:
 		*m = None
 	default:
@@ -89,55 +92,207 @@ func (m Method) String() string {
 }
 
 func init() {
-	flag.Var(&IPAnonymizationFlag, "anonymize.ip", "Valid values are \"none\" and \"netblock\".")
-
-	// Set up the local IP addresses to be ignored by the anonymization system.
-	// We want to anonymize our users but not ourselves.
-	localAddrs, err := net.InterfaceAddrs()
-	if err == nil {
-		for _, addr := range localAddrs {
-			if ipnet, ok := addr.(*net.IPNet); ok {
-				IgnoredIPs = append(IgnoredIPs, ipnet.IP)
-			}
-		}
-	}
+	flag.Var(&IPAnonymizationFlag, "anonymize.ip", "Valid values are \"none\", \"netblock\", and \"cryptopan\".")
+	flag.IntVar(&IPv4PrefixFlag, "anonymize.ipv4-prefix", DefaultIPv4Prefix, "Prefix length to which netblock anonymization truncates IPv4 addresses.")
+	flag.IntVar(&IPv6PrefixFlag, "anonymize.ipv6-prefix", DefaultIPv6Prefix, "Prefix length to which netblock anonymization truncates IPv6 addresses.")
+	flag.Var(&KeyFileFlag, "anonymize.key-file", "File containing the 32 byte secret key (raw or hex-encoded) used by cryptopan anonymization.")
 }
 
 // IPAnonymizer is the generic interface for all systems that try and ensure IP
 // addresses are not human identifiers. It is a problem with many potential
 // subtleties, so we permit multiple implementations. We anonymize the address
 // in-place. If you don't want the address to be modified, then make a copy
-// before you pass it in.
+// before you pass it in, or use the AddrAnonymizer interface instead.
 type IPAnonymizer interface {
 	IP(ip net.IP)
 	Contains(dst, ip net.IP) bool
 }
 
+// Config describes an IP anonymizer. Different data products may be subject to
+// different privacy policies, so the prefix lengths and the set of networks
+// that are never anonymized can be chosen per anonymizer.
+type Config struct {
+	// Method selects the anonymization technique.
+	Method Method
+	// IPv4Prefix and IPv6Prefix are the number of leading bits preserved by
+	// netblock anonymization. All remaining bits are zeroed. A value of zero
+	// zeroes the whole address.
+	IPv4Prefix int
+	IPv6Prefix int
+	// IgnoredNets and IgnoredPrefixes are sets of networks whose addresses
+	// should not be anonymized.
+	IgnoredNets     []*net.IPNet
+	IgnoredPrefixes []netip.Prefix
+	// LocalAddrs is the set of local addresses, which should not be
+	// anonymized. It may be refreshed while the anonymizer is in use. If it is
+	// nil, no addresses are treated as local.
+	LocalAddrs *LocalAddrs
+	// Key is the secret key used by the CryptoPAn method. It must be
+	// CryptoPAnKeySize bytes long. It is ignored by all other methods.
+	Key []byte
+}
+
+// ConfigFromFlags returns a Config populated from the `--anonymize.*`
+// command-line flags. The returned Config has no LocalAddrs.
+func ConfigFromFlags() Config {
+	return Config{
+		Method:     IPAnonymizationFlag,
+		IPv4Prefix: IPv4PrefixFlag,
+		IPv6Prefix: IPv6PrefixFlag,
+		Key:        decodeKey(KeyFileFlag.Bytes),
+	}
+}
+
+// ParseCIDRs parses each of the given strings as a CIDR network, for use as
+// Config.IgnoredNets. Bare addresses are accepted and treated as networks
+// containing only that one address.
+func ParseCIDRs(cidrs ...string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %q", c)
+			}
+			if ip4 := ip.To4(); ip4 != nil {
+				nets = append(nets, &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+			} else {
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+			}
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// Check returns an error if the config cannot be used to build an anonymizer.
+func (c Config) Check() error {
+	switch c.Method {
+	case None, Netblock:
+	case CryptoPAn:
+		if len(c.Key) != CryptoPAnKeySize {
+			return fmt.Errorf("CryptoPAn key must be %d bytes, not %d", CryptoPAnKeySize, len(c.Key))
+		}
+	default:
+		return fmt.Errorf("unknown anonymization method: %q", c.Method)
+	}
+	if c.IPv4Prefix < 0 || c.IPv4Prefix > 32 {
+		return fmt.Errorf("invalid IPv4 prefix length: %d", c.IPv4Prefix)
+	}
+	if c.IPv6Prefix < 0 || c.IPv6Prefix > 128 {
+		return fmt.Errorf("invalid IPv6 prefix length: %d", c.IPv6Prefix)
+	}
+	return nil
+}
+
 // New is an IP anonymization factory function that expects you to pass in
 // anonymize.IPAnonymizationFlag, which contains the contents of the
 // `--anonymize.ip` command-line flag.
 //
-// If the anonymization method is set to "netblock", then IPv4 addresses will be
-// anonymized up to the /24 level and IPv6 addresses to the /64 level. If it is
-// set to "none" then no anonymization will be performed. We can imagine future
-// anonymization techniques based on k-anonymity or that completely blot out the
-// IP. We leave room for those implementations here, but do not (yet) implement
-// them.
+// If the anonymization method is set to "netblock", then IPv4 and IPv6
+// addresses will be anonymized to the prefix lengths given by the
+// `--anonymize.ipv4-prefix` and `--anonymize.ipv6-prefix` flags (by default /24
+// and /48). If it is set to "cryptopan" then addresses will be replaced by
+// prefix-preserving pseudonyms derived from the `--anonymize.key-file` key. If it
+// is set to "none" then no anonymization will be performed. We
+// can imagine future anonymization techniques based on k-anonymity or that
+// completely blot out the IP. We leave room for those implementations here, but
+// do not (yet) implement them.
+//
+// The addresses of the local interfaces at the time New is called are not
+// anonymized. Programs whose addresses may change should create a LocalAddrs,
+// keep it refreshed, and pass it to NewFromConfig instead.
 //
 // A program attempting to perform IP anonymization should only ever create one
 // IPAnonymizer and use that one anonymizer for all connections. Otherwise, the
 // created IPAnonymizer will lack the necessary context to correctly perform
 // k-anonymization.
 func New(method Method) IPAnonymizer {
-	switch method {
-	case None:
-		return nullIPAnonymizer{}
+	c := ConfigFromFlags()
+	c.Method = method
+	local, err := NewLocalAddrs(net.InterfaceAddrs)
+	if err != nil {
+		log.Println("Could not get local addresses, none will be ignored:", err)
+	}
+	c.LocalAddrs = local
+	a, err := NewFromConfig(c)
+	if err != nil {
+		logFatalf("Could not create anonymizer: %v, exiting to avoid accidentally leaking private data", err)
+		panic("This line should only be reached during testing.")
+	}
+	return a
+}
+
+// NewFromConfig creates an IPAnonymizer as described by the passed-in Config.
+// Unlike New, it returns an error rather than exiting when the config is
+// invalid. The returned value also implements AddrAnonymizer.
+func NewFromConfig(c Config) (IPAnonymizer, error) {
+	if err := c.Check(); err != nil {
+		return nil, err
+	}
+	ignored := ignoreList{
+		local:    c.LocalAddrs,
+		prefixes: ignoredPrefixes(c),
+	}
+	switch c.Method {
 	case Netblock:
-		return netblockAnonymizer{}
+		return netblockAnonymizer{
+			v4bits:  c.IPv4Prefix,
+			v6bits:  c.IPv6Prefix,
+			ignored: ignored,
+		}, nil
+	case CryptoPAn:
+		return newCryptoPAnAnonymizer(c.Key, ignored)
 	default:
-		logFatalf("Unknown anonymization method: %q, exiting to avoid accidentally leaking private data", method)
-		panic("This line should only be reached during testing.")
+		return nullIPAnonymizer{}, nil
+	}
+}
+
+// ignoredPrefixes combines the IgnoredNets and IgnoredPrefixes of the config
+// into a single list of unmapped prefixes.
+func ignoredPrefixes(c Config) []netip.Prefix {
+	ignored := make([]netip.Prefix, 0, len(c.IgnoredNets)+len(c.IgnoredPrefixes))
+	for _, n := range c.IgnoredNets {
+		a, ok := netip.AddrFromSlice(n.IP)
+		if !ok {
+			continue
+		}
+		ones, bits := n.Mask.Size()
+		if a.Is4In6() && bits == 8*net.IPv6len {
+			ones -= 96
+		}
+		ignored = append(ignored, netip.PrefixFrom(a.Unmap(), ones).Masked())
+	}
+	for _, p := range c.IgnoredPrefixes {
+		ignored = append(ignored, netip.PrefixFrom(p.Addr().Unmap(), p.Bits()).Masked())
+	}
+	return ignored
+}
+
+// ignoreList describes the addresses an anonymizer should leave as-is.
+type ignoreList struct {
+	local    *LocalAddrs
+	prefixes []netip.Prefix
+}
+
+// contains returns whether the addr should be left as-is, either because it
+// is a local address or because it is in one of the ignored prefixes.
+func (l ignoreList) contains(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	if l.local.Contains(addr) {
+		return true
 	}
+	for i := range l.prefixes {
+		if l.prefixes[i].Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
 
 // nullIPAnonymizer does nothing.
@@ -148,57 +303,81 @@ func (nullIPAnonymizer) Contains(dst, ip net.IP) bool {
 	return dst.Equal(ip)
 }
 
-// netblockIPAnonymizer restricts v4 addresses to a /24 and v6 addresses to a /64
-type netblockAnonymizer struct{}
+func (nullIPAnonymizer) Addr(addr netip.Addr) netip.Addr {
+	return addr
+}
+
+func (nullIPAnonymizer) ContainsAddr(dst, addr netip.Addr) bool {
+	return dst.IsValid() && dst.Unmap() == addr.Unmap()
+}
+
+// netblockAnonymizer restricts v4 and v6 addresses to their containing
+// netblocks, as determined by the configured prefix lengths.
+type netblockAnonymizer struct {
+	v4bits  int
+	v6bits  int
+	ignored ignoreList
+}
 
-func (netblockAnonymizer) IP(ip net.IP) {
+func (n netblockAnonymizer) IP(ip net.IP) {
 	if ip == nil {
 		return
 	}
-	for i := range IgnoredIPs {
-		if IgnoredIPs[i].Equal(ip) {
-			return
-		}
-	}
-	if ip.To4() != nil {
-		// Zero out the last byte.  That's ip[3] in the 4-byte v4 representation and ip[15] in the v4-in-v6 representation.
-		ip[len(ip)-1] = 0
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		log.Println("The passed in IP address was neither a v4 nor a v6 address:", ip)
 		return
 	}
-	if ip.To16() != nil {
-		// Truncate IPv6 addresses to the containing /48
-		for i := 6; i < 16; i++ {
-			ip[i] = 0
-		}
-		return
-	}
-	log.Println("The passed in IP address was neither a v4 nor a v6 address:", ip)
-	return
+	writeAddr(ip, n.Addr(addr))
 }
 
 // Contains determines whether the dst IP (as if netblock anonymized), contains the given dst address.
 func (n netblockAnonymizer) Contains(dst, ip net.IP) bool {
-	if dst == nil || ip == nil {
+	d, ok := netip.AddrFromSlice(dst)
+	if !ok {
 		return false
 	}
-	for i := range IgnoredIPs {
-		if IgnoredIPs[i].Equal(dst) {
-			return false
-		}
+	a, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
 	}
-	if dst.To4() != nil {
-		nn := &net.IPNet{
-			IP:   dst,
-			Mask: net.CIDRMask(24, 32),
-		}
-		return nn.Contains(ip)
+	return n.ContainsAddr(d, a)
+}
+
+// prefix returns the netblock containing addr. The returned prefix is always
+// unmapped.
+func (n netblockAnonymizer) prefix(addr netip.Addr) netip.Prefix {
+	addr = addr.Unmap()
+	bits := n.v6bits
+	if addr.Is4() {
+		bits = n.v4bits
 	}
-	if dst.To16() != nil {
-		nn := &net.IPNet{
-			IP:   dst,
-			Mask: net.CIDRMask(48, 128),
-		}
-		return nn.Contains(ip)
+	// Prefix only fails for invalid addresses or out-of-range lengths, both of
+	// which are excluded by the callers and by Config.Check.
+	p, _ := addr.Prefix(bits)
+	return p
+}
+
+// Addr returns the first address of the netblock containing addr. v4-in-v6
+// addresses are returned in the same representation they were passed in.
+func (n netblockAnonymizer) Addr(addr netip.Addr) netip.Addr {
+	if !addr.IsValid() || n.ignored.contains(addr) {
+		return addr
 	}
-	return false
+	a := n.prefix(addr).Addr()
+	if addr.Is4In6() {
+		return netip.AddrFrom16(a.As16())
+	}
+	return a
+}
+
+// ContainsAddr determines whether the netblock of dst contains addr.
+func (n netblockAnonymizer) ContainsAddr(dst, addr netip.Addr) bool {
+	if !dst.IsValid() || !addr.IsValid() {
+		return false
+	}
+	if n.ignored.contains(dst) {
+		return false
+	}
+	return n.prefix(dst).Contains(addr.Unmap().WithZone(""))
 }
@@ -3,6 +3,7 @@ package anonymize_test
 import (
 	"log"
 	"net"
+	"net/netip"
 	"testing"
 
 	"github.com/go-test/deep"
@@ -42,20 +43,35 @@ func TestBadAnonName(t *testing.T) {
 	anonymize.New(anonymize.Method("bad_anon_method"))
 }
 
+// newNetblock returns a default netblock anonymizer that treats the passed-in
+// addresses as local.
+func newNetblock(local ...string) anonymize.IPAnonymizer {
+	addrs := make([]netip.Addr, len(local))
+	for i := range local {
+		addrs[i] = netip.MustParseAddr(local[i])
+	}
+	anon, err := anonymize.NewFromConfig(anonymize.Config{
+		Method:     anonymize.Netblock,
+		IPv4Prefix: anonymize.DefaultIPv4Prefix,
+		IPv6Prefix: anonymize.DefaultIPv6Prefix,
+		LocalAddrs: anonymize.StaticLocalAddrs(addrs...),
+	})
+	rtx.Must(err, "Could not create anonymizer")
+	return anon
+}
+
 func TestNetblockAnon(t *testing.T) {
-	anon := anonymize.New(anonymize.Netblock)
+	anon := newNetblock("127.0.0.1", "1::2")
 
 	anon.IP(nil)                  // No crash = success
 	anon.IP(net.IP([]byte{1, 2})) // No crash = success
 
-	anonymize.IgnoredIPs = []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("1::2")}
-
 	tests := []struct {
 		ip   string
 		want string
 	}{
-		{"127.0.0.1", "127.0.0.1"}, // IgnoredIPs should be ignored.
-		{"1:0::2", "1::2"},         // IgnoredIPs should be ignored.
+		{"127.0.0.1", "127.0.0.1"}, // LocalAddrs should be ignored.
+		{"1:0::2", "1::2"},         // LocalAddrs should be ignored.
 		{"10.1.2.3", "10.1.2.0"},
 		{"255.255.255.255", "255.255.255.0"},
 		{"0:1:2:3:4:5:6:7", "0:1:2::"},
@@ -102,7 +118,7 @@ func Example() {
 }
 
 func TestAnonymizerContains(t *testing.T) {
-	anonymize.IgnoredIPs = []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("1::2")}
+	netblock := newNetblock("127.0.0.1", "1::2")
 	tests := []struct {
 		name string
 		n    anonymize.IPAnonymizer
@@ -113,14 +129,14 @@ func TestAnonymizerContains(t *testing.T) {
 		// Does the destination IP (as a netblock) contain the given ip?
 		{
 			name: "success-ipv4",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  net.ParseIP("192.168.0.1"),
 			ip:   net.ParseIP("192.168.0.2"),
 			want: true,
 		},
 		{
 			name: "success-ipv4",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  net.ParseIP("192.168.0.2"),
 			ip:   net.ParseIP("192.168.0.1"),
 			want: true,
@@ -141,56 +157,56 @@ func TestAnonymizerContains(t *testing.T) {
 		},
 		{
 			name: "success-ipv6",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  net.ParseIP("fd12:3456:789a:1::1"),
 			ip:   net.ParseIP("fd12:3456:789a:1::2"),
 			want: true,
 		},
 		{
 			name: "success-ipv6",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  net.ParseIP("fd12:3456:789a:1::2"),
 			ip:   net.ParseIP("fd12:3456:789a:1::1"),
 			want: true,
 		},
 		{
 			name: "success-ignored",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  net.ParseIP("127.0.0.1"),
 			ip:   net.ParseIP("127.0.0.1"),
 			want: false,
 		},
 		{
 			name: "success-nil-dst-arg",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  nil,
 			ip:   net.ParseIP("127.0.0.1"),
 			want: false,
 		},
 		{
 			name: "success-nil-ip-arg",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  net.ParseIP("127.0.0.1"),
 			ip:   nil,
 			want: false,
 		},
 		{
 			name: "error-invalid-byte-array-1-byte-ip",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  []byte{'0'},
 			ip:   net.ParseIP("127.0.0.1"),
 			want: false,
 		},
 		{
 			name: "error-invalid-byte-array-5-byte-ip",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  append(net.ParseIP("127.0.0.1"), '0'),
 			ip:   net.ParseIP("127.0.0.1"),
 			want: false,
 		},
 		{
 			name: "error-invalid-byte-array-17-byte-ip",
-			n:    anonymize.New(anonymize.Netblock),
+			n:    netblock,
 			dst:  append(net.ParseIP("fd12:3456:789a:1::1"), '0'),
 			ip:   net.ParseIP("2::1"),
 			want: false,
@@ -205,3 +221,133 @@ func TestAnonymizerContains(t *testing.T) {
 		})
 	}
 }
+
+func TestNewFromConfig(t *testing.T) {
+	ignored, err := anonymize.ParseCIDRs("10.99.0.0/16", "2001:db8::/32", "192.168.1.1")
+	rtx.Must(err, "Could not parse CIDRs")
+	tests := []struct {
+		name   string
+		config anonymize.Config
+		ip     string
+		want   string
+	}{
+		{
+			name:   "ipv4-16",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 16, IPv6Prefix: 48},
+			ip:     "10.1.2.3",
+			want:   "10.1.0.0",
+		},
+		{
+			name:   "ipv4-20",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 20, IPv6Prefix: 48},
+			ip:     "10.1.255.3",
+			want:   "10.1.240.0",
+		},
+		{
+			name:   "ipv4-32",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 32, IPv6Prefix: 48},
+			ip:     "10.1.2.3",
+			want:   "10.1.2.3",
+		},
+		{
+			name:   "ipv4-0",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 0, IPv6Prefix: 48},
+			ip:     "10.1.2.3",
+			want:   "0.0.0.0",
+		},
+		{
+			name:   "ipv6-64",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 24, IPv6Prefix: 64},
+			ip:     "aaaa:aaab:aaac:aaad:aaae:aaaf:aaa1:aaa1",
+			want:   "aaaa:aaab:aaac:aaad::",
+		},
+		{
+			name:   "ipv6-56",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 24, IPv6Prefix: 56},
+			ip:     "aaaa:aaab:aaac:aaad:aaae:aaaf:aaa1:aaa1",
+			want:   "aaaa:aaab:aaac:aa00::",
+		},
+		{
+			name:   "ignored-v4-cidr",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 24, IPv6Prefix: 48, IgnoredNets: ignored},
+			ip:     "10.99.2.3",
+			want:   "10.99.2.3",
+		},
+		{
+			name:   "ignored-v4-single",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 24, IPv6Prefix: 48, IgnoredNets: ignored},
+			ip:     "192.168.1.1",
+			want:   "192.168.1.1",
+		},
+		{
+			name:   "not-ignored-v4-single",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 24, IPv6Prefix: 48, IgnoredNets: ignored},
+			ip:     "192.168.1.2",
+			want:   "192.168.1.0",
+		},
+		{
+			name:   "ignored-v6-cidr",
+			config: anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 24, IPv6Prefix: 48, IgnoredNets: ignored},
+			ip:     "2001:db8:1:2::3",
+			want:   "2001:db8:1:2::3",
+		},
+		{
+			name:   "none",
+			config: anonymize.Config{Method: anonymize.None},
+			ip:     "10.1.2.3",
+			want:   "10.1.2.3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anon, err := anonymize.NewFromConfig(tt.config)
+			rtx.Must(err, "Could not create anonymizer")
+			ip := net.ParseIP(tt.ip)
+			anon.IP(ip)
+			if ip.String() != tt.want {
+				t.Errorf("IP() = %q, want %q", ip.String(), tt.want)
+			}
+			if tt.config.Method == anonymize.Netblock && tt.ip != tt.want && !anon.Contains(net.ParseIP(tt.ip), ip) {
+				t.Errorf("Contains(%q, %q) = false, want true", tt.ip, ip)
+			}
+		})
+	}
+}
+
+func TestNewFromConfigErrors(t *testing.T) {
+	for _, c := range []anonymize.Config{
+		{Method: "bad_anon_method"},
+		{Method: anonymize.Netblock, IPv4Prefix: -1},
+		{Method: anonymize.Netblock, IPv4Prefix: 33},
+		{Method: anonymize.Netblock, IPv6Prefix: -1},
+		{Method: anonymize.Netblock, IPv6Prefix: 129},
+	} {
+		if _, err := anonymize.NewFromConfig(c); err == nil {
+			t.Errorf("NewFromConfig(%+v) should have returned an error", c)
+		}
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := anonymize.ParseCIDRs("10.0.0.0/8", "::1", "127.0.0.1")
+	rtx.Must(err, "Could not parse CIDRs")
+	want := []string{"10.0.0.0/8", "::1/128", "127.0.0.1/32"}
+	for i := range want {
+		if nets[i].String() != want[i] {
+			t.Errorf("ParseCIDRs()[%d] = %q, want %q", i, nets[i].String(), want[i])
+		}
+	}
+	if _, err := anonymize.ParseCIDRs("10.0.0.0/33"); err == nil {
+		t.Error("ParseCIDRs() should have failed on a bad prefix length")
+	}
+	if _, err := anonymize.ParseCIDRs("not-an-ip"); err == nil {
+		t.Error("ParseCIDRs() should have failed on a bad address")
+	}
+}
+
+func TestConfigFromFlags(t *testing.T) {
+	c := anonymize.ConfigFromFlags()
+	if c.IPv4Prefix != anonymize.DefaultIPv4Prefix || c.IPv6Prefix != anonymize.DefaultIPv6Prefix {
+		t.Errorf("ConfigFromFlags() = %+v, want default prefix lengths", c)
+	}
+}
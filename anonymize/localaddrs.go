@@ -0,0 +1,117 @@
+package anonymize
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/netip"
+	"slices"
+	"sync/atomic"
+
+	"github.com/m-lab/go/memoryless"
+)
+
+// LocalAddrs is a refreshable set of addresses that belong to the local host.
+// We want to anonymize our users but not ourselves, so anonymizers never
+// anonymize the addresses in their LocalAddrs. The set of addresses can change
+// over the lifetime of a program (containers, hotplugged interfaces, IPv6
+// SLAAC), so it can be refreshed either periodically with RefreshEvery or, on
+// Linux, whenever the kernel reports an address change with WatchNetlink.
+//
+// LocalAddrs is safe for concurrent use. A nil *LocalAddrs contains nothing.
+type LocalAddrs struct {
+	source func() ([]net.Addr, error)
+	addrs  atomic.Pointer[map[netip.Addr]struct{}]
+}
+
+// NewLocalAddrs returns a LocalAddrs populated by the passed-in source. Most
+// programs should pass net.InterfaceAddrs.
+func NewLocalAddrs(source func() ([]net.Addr, error)) (*LocalAddrs, error) {
+	l := &LocalAddrs{source: source}
+	if err := l.Refresh(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// StaticLocalAddrs returns a LocalAddrs containing exactly the passed-in
+// addresses. Refreshing it has no effect.
+func StaticLocalAddrs(addrs ...netip.Addr) *LocalAddrs {
+	l := &LocalAddrs{}
+	l.store(addrs)
+	return l
+}
+
+func (l *LocalAddrs) store(addrs []netip.Addr) {
+	m := make(map[netip.Addr]struct{}, len(addrs))
+	for _, a := range addrs {
+		m[a.Unmap().WithZone("")] = struct{}{}
+	}
+	l.addrs.Store(&m)
+}
+
+// Refresh replaces the set of local addresses with the current output of the
+// source. On error, the previous set is kept.
+func (l *LocalAddrs) Refresh() error {
+	if l.source == nil {
+		return nil
+	}
+	netAddrs, err := l.source()
+	if err != nil {
+		return err
+	}
+	addrs := make([]netip.Addr, 0, len(netAddrs))
+	for _, na := range netAddrs {
+		var ip net.IP
+		switch v := na.(type) {
+		case *net.IPNet:
+			ip = v.IP
+		case *net.IPAddr:
+			ip = v.IP
+		}
+		if a, ok := netip.AddrFromSlice(ip); ok {
+			addrs = append(addrs, a)
+		}
+	}
+	l.store(addrs)
+	return nil
+}
+
+// Contains returns whether addr is one of the local addresses. v4-in-v6
+// addresses match their v4 equivalents.
+func (l *LocalAddrs) Contains(addr netip.Addr) bool {
+	if l == nil {
+		return false
+	}
+	m := l.addrs.Load()
+	if m == nil {
+		return false
+	}
+	_, ok := (*m)[addr.Unmap().WithZone("")]
+	return ok
+}
+
+// Addrs returns the current set of local addresses, sorted.
+func (l *LocalAddrs) Addrs() []netip.Addr {
+	if l == nil || l.addrs.Load() == nil {
+		return nil
+	}
+	m := *l.addrs.Load()
+	addrs := make([]netip.Addr, 0, len(m))
+	for a := range m {
+		addrs = append(addrs, a)
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+	return addrs
+}
+
+// RefreshEvery refreshes the local addresses on a memoryless schedule until the
+// context is canceled. Refresh errors are logged, and the previous addresses
+// are kept. It returns an error only if the config is invalid.
+func (l *LocalAddrs) RefreshEvery(ctx context.Context, c memoryless.Config) error {
+	return memoryless.Run(ctx, func() {
+		if err := l.Refresh(); err != nil {
+			log.Println("Could not refresh local addresses:", err)
+		}
+	}, c)
+}
@@ -0,0 +1,67 @@
+package anonymize
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"syscall"
+)
+
+// Multicast groups from <linux/rtnetlink.h>, which the syscall package lacks.
+const (
+	rtmgrpIPv4IfAddr = 0x10
+	rtmgrpIPv6IfAddr = 0x100
+)
+
+// WatchNetlink refreshes the local addresses every time the kernel reports
+// that an interface address was added or removed, until the context is
+// canceled.
+func (l *LocalAddrs) WatchNetlink(ctx context.Context) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC|syscall.SOCK_NONBLOCK, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	sa := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmgrpIPv4IfAddr | rtmgrpIPv6IfAddr,
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+	// Because the socket is non-blocking, os.NewFile registers it with the
+	// runtime poller, which allows the blocked Read below to be interrupted by
+	// Close.
+	f := os.NewFile(uintptr(fd), "netlink")
+	defer f.Close()
+	// done stops the goroutine below when the loop returns an error before the
+	// context is canceled.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.Close()
+		case <-done:
+		}
+	}()
+
+	// There is no need to parse the messages. Any message in the subscribed
+	// groups means the addresses changed, and the cheapest correct response is
+	// to reload all of them.
+	buf := make([]byte, os.Getpagesize())
+	for {
+		_, err := f.Read(buf)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && !errors.Is(err, syscall.ENOBUFS) {
+			return err
+		}
+		// ENOBUFS means messages were dropped, which is also a reason to reload.
+		if err := l.Refresh(); err != nil {
+			log.Println("Could not refresh local addresses:", err)
+		}
+	}
+}
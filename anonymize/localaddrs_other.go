@@ -0,0 +1,13 @@
+//go:build !linux
+
+package anonymize
+
+import (
+	"context"
+	"errors"
+)
+
+// WatchNetlink is only supported on Linux. Elsewhere, use RefreshEvery.
+func (l *LocalAddrs) WatchNetlink(ctx context.Context) error {
+	return errors.New("netlink is not supported on this platform")
+}
@@ -0,0 +1,138 @@
+package anonymize_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/anonymize"
+	"github.com/m-lab/go/memoryless"
+	"github.com/m-lab/go/rtx"
+)
+
+// fakeSource returns a different set of addresses on every call.
+type fakeSource struct {
+	addrs [][]net.Addr
+	err   error
+	calls int
+}
+
+func (f *fakeSource) get() ([]net.Addr, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	a := f.addrs[f.calls%len(f.addrs)]
+	f.calls++
+	return a, nil
+}
+
+func TestLocalAddrs(t *testing.T) {
+	src := &fakeSource{
+		addrs: [][]net.Addr{
+			{
+				&net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(24, 32)},
+				&net.IPAddr{IP: net.ParseIP("2001:db8::1")},
+				&net.UnixAddr{Name: "/tmp/sock"},
+			},
+			{
+				&net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(24, 32)},
+			},
+		},
+	}
+	local, err := anonymize.NewLocalAddrs(src.get)
+	rtx.Must(err, "Could not create LocalAddrs")
+	anon, err := anonymize.NewFromConfig(anonymize.Config{
+		Method:     anonymize.Netblock,
+		IPv4Prefix: 24,
+		IPv6Prefix: 48,
+		LocalAddrs: local,
+	})
+	rtx.Must(err, "Could not create anonymizer")
+
+	want := []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("2001:db8::1")}
+	if diff := deep.Equal(local.Addrs(), want); diff != nil {
+		t.Error(diff)
+	}
+	if !local.Contains(netip.MustParseAddr("::ffff:10.0.0.1")) {
+		t.Error("Contains() should match v4-in-v6 addresses")
+	}
+	ip := net.ParseIP("10.0.0.1")
+	anon.IP(ip)
+	if ip.String() != "10.0.0.1" {
+		t.Errorf("IP() anonymized a local address: %v", ip)
+	}
+
+	// After a refresh, the anonymizer should see the new addresses.
+	rtx.Must(local.Refresh(), "Could not refresh")
+	ip = net.ParseIP("10.0.0.1")
+	anon.IP(ip)
+	if ip.String() != "10.0.0.0" {
+		t.Errorf("IP() did not anonymize a former local address: %v", ip)
+	}
+	ip = net.ParseIP("10.0.0.2")
+	anon.IP(ip)
+	if ip.String() != "10.0.0.2" {
+		t.Errorf("IP() anonymized a new local address: %v", ip)
+	}
+
+	// A failed refresh keeps the previous addresses.
+	src.err = errors.New("fake error")
+	if local.Refresh() == nil {
+		t.Error("Refresh() should have failed")
+	}
+	if !local.Contains(netip.MustParseAddr("10.0.0.2")) {
+		t.Error("A failed Refresh() should not change the addresses")
+	}
+	if _, err := anonymize.NewLocalAddrs(src.get); err == nil {
+		t.Error("NewLocalAddrs() should have failed")
+	}
+}
+
+func TestStaticLocalAddrs(t *testing.T) {
+	local := anonymize.StaticLocalAddrs(netip.MustParseAddr("fe80::1%eth0"))
+	rtx.Must(local.Refresh(), "Refresh of static addresses should not fail")
+	if !local.Contains(netip.MustParseAddr("fe80::1")) {
+		t.Error("Contains() should ignore zones")
+	}
+	var nilLocal *anonymize.LocalAddrs
+	if nilLocal.Contains(netip.MustParseAddr("127.0.0.1")) || nilLocal.Addrs() != nil {
+		t.Error("A nil LocalAddrs should be empty")
+	}
+}
+
+func TestLocalAddrs_RefreshEvery(t *testing.T) {
+	src := &fakeSource{addrs: [][]net.Addr{{}}}
+	local, err := anonymize.NewLocalAddrs(src.get)
+	rtx.Must(err, "Could not create LocalAddrs")
+	rtx.Must(local.RefreshEvery(context.Background(), memoryless.Config{Once: true}), "Could not refresh")
+	if src.calls != 2 {
+		t.Errorf("source was called %d times, want 2", src.calls)
+	}
+	src.err = errors.New("errors should be logged, not returned")
+	rtx.Must(local.RefreshEvery(context.Background(), memoryless.Config{Once: true}), "Could not refresh")
+	if local.RefreshEvery(context.Background(), memoryless.Config{Expected: -1}) == nil {
+		t.Error("RefreshEvery() should fail with a bad config")
+	}
+}
+
+func TestLocalAddrs_WatchNetlink(t *testing.T) {
+	local, err := anonymize.NewLocalAddrs(net.InterfaceAddrs)
+	rtx.Must(err, "Could not create LocalAddrs")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := local.WatchNetlink(ctx); err != nil {
+		t.Skip("netlink is unavailable:", err)
+	}
+}
+
+func TestNewIgnoresInterfaceAddrs(t *testing.T) {
+	ip := net.ParseIP("127.0.0.1")
+	anonymize.New(anonymize.Netblock).IP(ip)
+	if ip.String() != "127.0.0.1" {
+		t.Errorf("New() should not anonymize local addresses, but got %v", ip)
+	}
+}
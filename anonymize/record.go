@@ -0,0 +1,255 @@
+package anonymize
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"reflect"
+	"sync"
+)
+
+// TagName is the struct tag key consulted by Record. The only supported value
+// is "ip", as in:
+//
+//	type Connection struct {
+//	    ClientIP string       `anonymize:"ip"`
+//	    ServerIP net.IP       `anonymize:"ip"`
+//	    Hops     []netip.Addr `anonymize:"ip"`
+//	}
+const TagName = "anonymize"
+
+var (
+	ipType   = reflect.TypeOf(net.IP(nil))
+	addrType = reflect.TypeOf(netip.Addr{})
+
+	// mayContainTagsCache maps a reflect.Type to whether values of that type
+	// might contain tagged fields.
+	mayContainTagsCache sync.Map
+)
+
+// Record applies the anonymizer to every field tagged with `anonymize:"ip"` in
+// the value pointed to by record. Untagged fields are searched recursively
+// through nested structs, pointers, slices, arrays, maps and interfaces.
+//
+// Tagged fields may be strings, net.IP or netip.Addr values, or pointers,
+// slices, arrays or maps of those. Empty strings and nil IPs are left alone.
+// Any other string that is not an IP address, or any other tagged type, causes
+// an error to be returned, as silently passing it through might leak private
+// data. Unexported fields are never examined.
+//
+// Tagged net.IP fields are replaced by anonymized copies, so backing arrays
+// shared with other data are not modified. Addresses shared by several tagged
+// fields, through pointers, slices or maps, are anonymized only once.
+func Record(a IPAnonymizer, record interface{}) error {
+	v := reflect.ValueOf(record)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("anonymize.Record requires a non-nil pointer, not %T", record)
+	}
+	w := &walker{
+		anon:    a,
+		addr:    AsAddrAnonymizer(a),
+		visited: make(map[uintptr]bool),
+		done:    make(map[location]bool),
+	}
+	return w.walk(v.Elem(), v.Elem().Type().Name())
+}
+
+// walker holds the state of a single call to Record.
+type walker struct {
+	anon IPAnonymizer
+	addr AddrAnonymizer
+	// visited records pointers that have already been walked, so that cyclic
+	// and shared data is anonymized exactly once.
+	visited map[uintptr]bool
+	// done records the tagged values that have already been anonymized, so
+	// that values reached through several tagged pointers, slices or maps are
+	// not anonymized again. This matters for methods like CryptoPAn, which
+	// give a different result when applied twice.
+	done map[location]bool
+}
+
+// location identifies a value in memory. The type is needed because a struct
+// and its first field share an address.
+type location struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// seen returns whether the value at loc was already anonymized, and records
+// that it now is.
+func (w *walker) seen(loc location) bool {
+	if w.done[loc] {
+		return true
+	}
+	w.done[loc] = true
+	return false
+}
+
+// walk searches v for tagged fields.
+func (w *walker) walk(v reflect.Value, path string) error {
+	if !mayContainTags(v.Type()) {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || w.visited[v.Pointer()] {
+			return nil
+		}
+		w.visited[v.Pointer()] = true
+		return w.walk(v.Elem(), path)
+	case reflect.Interface:
+		return w.viaCopy(v, path, w.walk)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fpath := path + "." + f.Name
+			tag, ok := f.Tag.Lookup(TagName)
+			var err error
+			switch {
+			case !ok:
+				err = w.walk(v.Field(i), fpath)
+			case tag == "ip":
+				err = w.anonymize(v.Field(i), fpath)
+			default:
+				err = fmt.Errorf("unknown %s tag %q on %s", TagName, tag, fpath)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := w.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		return w.mapValues(v, path, w.walk)
+	}
+	return nil
+}
+
+// anonymize anonymizes the IP addresses contained in the tagged value v.
+func (w *walker) anonymize(v reflect.Value, path string) error {
+	// Addressable values may be shared with other tagged fields. Values that
+	// are not addressable are copies, which cannot be shared.
+	leaf := v.Type() == addrType || v.Type() == ipType || v.Kind() == reflect.String
+	if leaf && v.CanAddr() && w.seen(location{v.UnsafeAddr(), v.Type()}) {
+		return nil
+	}
+	switch {
+	case v.Type() == addrType:
+		v.Set(reflect.ValueOf(w.addr.Addr(v.Interface().(netip.Addr))))
+		return nil
+	case v.Type() == ipType:
+		if !v.IsNil() {
+			v.Set(reflect.ValueOf(anonymizedCopy(w.anon, v.Interface().(net.IP))))
+		}
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		if v.String() == "" {
+			return nil
+		}
+		addr, err := netip.ParseAddr(v.String())
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an IP address: %w", path, v.String(), err)
+		}
+		v.SetString(w.addr.Addr(addr).String())
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return w.anonymize(v.Elem(), path)
+		}
+	case reflect.Interface:
+		return w.viaCopy(v, path, w.anonymize)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := w.anonymize(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() || w.seen(location{v.Pointer(), v.Type()}) {
+			return nil
+		}
+		return w.mapValues(v, path, w.anonymize)
+	default:
+		return fmt.Errorf("%s: cannot anonymize IPs in a value of type %s", path, v.Type())
+	}
+	return nil
+}
+
+// viaCopy applies f to a settable copy of the value contained in the interface
+// v, and stores the result back into v. Values contained in interfaces are not
+// addressable, so they cannot be modified directly.
+func (w *walker) viaCopy(v reflect.Value, path string, f func(reflect.Value, string) error) error {
+	if v.IsNil() {
+		return nil
+	}
+	c := reflect.New(v.Elem().Type()).Elem()
+	c.Set(v.Elem())
+	if err := f(c, path); err != nil {
+		return err
+	}
+	v.Set(c)
+	return nil
+}
+
+// mapValues applies f to a settable copy of every value in the map v, and
+// stores the results back into the map.
+func (w *walker) mapValues(v reflect.Value, path string, f func(reflect.Value, string) error) error {
+	iter := v.MapRange()
+	for iter.Next() {
+		c := reflect.New(v.Type().Elem()).Elem()
+		c.Set(iter.Value())
+		if err := f(c, fmt.Sprintf("%s[%v]", path, iter.Key())); err != nil {
+			return err
+		}
+		v.SetMapIndex(iter.Key(), c)
+	}
+	return nil
+}
+
+// mayContainTags returns whether a value of type t might contain a tagged
+// field. Interfaces might contain anything, so they always might.
+func mayContainTags(t reflect.Type) bool {
+	if b, ok := mayContainTagsCache.Load(t); ok {
+		return b.(bool)
+	}
+	b := computeMayContainTags(t, make(map[reflect.Type]bool))
+	mayContainTagsCache.Store(t, b)
+	return b
+}
+
+func computeMayContainTags(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		// Recursive types are fully explored by the first visit.
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return computeMayContainTags(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if _, ok := f.Tag.Lookup(TagName); ok {
+				return true
+			}
+			if computeMayContainTags(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package anonymize_test
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/anonymize"
+	"github.com/m-lab/go/rtx"
+)
+
+type hop struct {
+	Addr netip.Addr `anonymize:"ip"`
+	RTT  float64
+}
+
+type server struct {
+	IP   net.IP `anonymize:"ip"`
+	Site string
+}
+
+type connection struct {
+	ClientIP   string   `anonymize:"ip"`
+	OptionalIP *string  `anonymize:"ip"`
+	OtherIPs   []string `anonymize:"ip"`
+	Hostname   string
+	Server     server
+	ServerPtr  *server
+	Hops       []hop
+	ByName     map[string]hop
+	ByNamePtr  map[string]*hop
+	Extra      interface{}
+	Raw        []byte
+	Next       *connection
+	private    string `anonymize:"ip"`
+}
+
+func TestRecord(t *testing.T) {
+	anon := newNetblock()
+	optional := "10.0.0.7"
+	shared := net.ParseIP("10.2.2.2")
+	c := &connection{
+		ClientIP:   "10.1.1.1",
+		OptionalIP: &optional,
+		OtherIPs:   []string{"10.1.1.2", "", "2001:db8:1:2::3"},
+		Hostname:   "mlab1-lga01",
+		Server:     server{IP: shared, Site: "lga01"},
+		ServerPtr:  &server{IP: net.ParseIP("10.3.3.3")},
+		Hops:       []hop{{Addr: netip.MustParseAddr("10.4.4.4"), RTT: 1.5}},
+		ByName:     map[string]hop{"a": {Addr: netip.MustParseAddr("10.5.5.5")}},
+		ByNamePtr:  map[string]*hop{"b": {Addr: netip.MustParseAddr("10.6.6.6")}, "nil": nil},
+		Extra:      server{IP: net.ParseIP("10.7.7.7")},
+		Raw:        []byte{1, 2, 3},
+		private:    "10.8.8.8",
+	}
+	// A cycle should not cause infinite recursion.
+	c.Next = c
+
+	rtx.Must(anonymize.Record(anon, c), "Could not anonymize record")
+
+	optionalWant := "10.0.0.0"
+	want := &connection{
+		ClientIP:   "10.1.1.0",
+		OptionalIP: &optionalWant,
+		OtherIPs:   []string{"10.1.1.0", "", "2001:db8:1::"},
+		Hostname:   "mlab1-lga01",
+		Server:     server{IP: net.ParseIP("10.2.2.0"), Site: "lga01"},
+		ServerPtr:  &server{IP: net.ParseIP("10.3.3.0")},
+		Hops:       []hop{{Addr: netip.MustParseAddr("10.4.4.0"), RTT: 1.5}},
+		ByName:     map[string]hop{"a": {Addr: netip.MustParseAddr("10.5.5.0")}},
+		ByNamePtr:  map[string]*hop{"b": {Addr: netip.MustParseAddr("10.6.6.0")}, "nil": nil},
+		Extra:      server{IP: net.ParseIP("10.7.7.0")},
+		Raw:        []byte{1, 2, 3},
+		private:    "10.8.8.8",
+	}
+	// Break the cycle before comparing.
+	c.Next = nil
+	deep.CompareUnexportedFields = true
+	defer func() { deep.CompareUnexportedFields = false }()
+	if diff := deep.Equal(c, want); diff != nil {
+		t.Error(diff)
+	}
+	if shared.String() != "10.2.2.2" {
+		t.Errorf("Record() modified a shared net.IP: %v", shared)
+	}
+}
+
+func TestRecord_Aliases(t *testing.T) {
+	// CryptoPAn, unlike netblock anonymization, gives a different result when
+	// applied twice, so every address must be anonymized exactly once.
+	anon, err := anonymize.NewFromConfig(anonymize.Config{Method: anonymize.CryptoPAn, Key: cryptoPAnKey})
+	rtx.Must(err, "Could not create anonymizer")
+	want := anon.(anonymize.AddrAnonymizer).Addr(netip.MustParseAddr("10.1.2.3")).String()
+
+	type aliases struct {
+		A     *string           `anonymize:"ip"`
+		B     *string           `anonymize:"ip"`
+		S     []string          `anonymize:"ip"`
+		T     []string          `anonymize:"ip"`
+		M     map[string]string `anonymize:"ip"`
+		N     map[string]string `anonymize:"ip"`
+		Addrs []netip.Addr      `anonymize:"ip"`
+		Ptr   *netip.Addr       `anonymize:"ip"`
+	}
+	ip := "10.1.2.3"
+	ips := []string{"10.1.2.3", "10.1.2.3"}
+	m := map[string]string{"a": "10.1.2.3"}
+	addrs := []netip.Addr{netip.MustParseAddr("10.1.2.3")}
+	r := &aliases{A: &ip, B: &ip, S: ips, T: ips[1:], M: m, N: m, Addrs: addrs, Ptr: &addrs[0]}
+
+	rtx.Must(anonymize.Record(anon, r), "Could not anonymize record")
+
+	for name, got := range map[string]string{
+		"A": *r.A, "S[0]": r.S[0], "S[1]": r.S[1], "M": r.M["a"], "Addrs": r.Addrs[0].String(),
+	} {
+		if got != want {
+			t.Errorf("Record() %s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	anon := anonymize.New(anonymize.Netblock)
+	type badTag struct {
+		IP string `anonymize:"mac"`
+	}
+	type badType struct {
+		Port int `anonymize:"ip"`
+	}
+	type badString struct {
+		IP string `anonymize:"ip"`
+	}
+	type nested struct {
+		Inner []badString
+	}
+	s := "not an ip"
+	tests := []struct {
+		name   string
+		record interface{}
+	}{
+		{"not-a-pointer", badString{IP: "10.0.0.1"}},
+		{"nil-pointer", (*badString)(nil)},
+		{"bad-tag", &badTag{IP: "10.0.0.1"}},
+		{"bad-type", &badType{Port: 80}},
+		{"bad-string", &badString{IP: "mlab1-lga01"}},
+		{"nested-bad-string", &nested{Inner: []badString{{IP: "10.0.0.1"}, {IP: s}}}},
+		{"map-bad-string", &map[string]badString{"a": {IP: s}}},
+		{"interface-bad-string", &[]interface{}{badString{IP: s}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := anonymize.Record(anon, tt.record); err == nil {
+				t.Errorf("Record(%#v) should have returned an error", tt.record)
+			}
+		})
+	}
+}
+
+func ExampleRecord() {
+	type Row struct {
+		Client string `anonymize:"ip"`
+		Server string
+	}
+	r := &Row{Client: "192.168.1.17", Server: "10.0.0.1"}
+	anon, _ := anonymize.NewFromConfig(anonymize.Config{Method: anonymize.Netblock, IPv4Prefix: 24})
+	anonymize.Record(anon, r)
+	fmt.Println(r.Client, r.Server)
+	// Output: 192.168.1.0 10.0.0.1
+}
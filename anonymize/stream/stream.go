@@ -0,0 +1,302 @@
+// Package stream anonymizes the IP addresses contained in archives of JSONL or
+// CSV records, for use when data must be anonymized after it has been
+// collected.
+package stream
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strings"
+
+	"github.com/m-lab/go/anonymize"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Supported stream formats.
+const (
+	JSONL = "jsonl"
+	CSV   = "csv"
+)
+
+var (
+	// ErrUnknownFormat is returned by Transform for unsupported formats.
+	ErrUnknownFormat = errors.New("unknown stream format")
+
+	recordsProcessed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "anonymize_stream_records_total",
+			Help: "The number of records processed by the stream anonymizer.",
+		},
+		[]string{"format"},
+	)
+	addressesProcessed = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "anonymize_stream_addresses_total",
+			Help: "The number of IP addresses processed by the stream anonymizer.",
+		},
+		[]string{"format"},
+	)
+)
+
+// Transformer anonymizes the IP addresses found in named fields of every record
+// in a stream.
+type Transformer struct {
+	// Anonymizer is applied to every address found.
+	Anonymizer anonymize.IPAnonymizer
+	// Fields names the fields that contain IP addresses. For JSONL, each field
+	// is a dot-separated path into the JSON object, e.g. "Client.IP". Arrays
+	// along the path are traversed element by element. For CSV, each field is
+	// a column name from the header row.
+	Fields []string
+}
+
+// New creates a Transformer for the given anonymizer and fields.
+func New(a anonymize.IPAnonymizer, fields ...string) *Transformer {
+	return &Transformer{
+		Anonymizer: a,
+		Fields:     fields,
+	}
+}
+
+// Transform reads records of the given format from r and writes their
+// anonymized versions to w.
+func (t *Transformer) Transform(format string, r io.Reader, w io.Writer) error {
+	switch format {
+	case JSONL:
+		return t.JSONL(r, w)
+	case CSV:
+		return t.CSV(r, w)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// anonymize returns the anonymized form of the address s. Empty strings are
+// returned unchanged. Anything else that is not an IP address is an error, as
+// passing it through might leak private data.
+func (t *Transformer) anonymize(format, s string) (string, error) {
+	if s == "" {
+		return s, nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return "", fmt.Errorf("%q is not an IP address: %w", s, err)
+	}
+	addressesProcessed.WithLabelValues(format).Inc()
+	return anonymize.AsAddrAnonymizer(t.Anonymizer).Addr(addr).String(), nil
+}
+
+// JSONL reads a stream of JSON objects from r and writes them to w with every
+// address in the configured fields anonymized, one object per line. Fields that
+// are absent or null are left alone. Only the configured fields are rewritten:
+// key order, numbers and other values are copied from the input as they are,
+// although each object is compacted onto a single line.
+func (t *Transformer) JSONL(r io.Reader, w io.Writer) error {
+	paths := make([][]string, len(t.Fields))
+	for i, f := range t.Fields {
+		paths[i] = strings.Split(f, ".")
+	}
+	dec := json.NewDecoder(r)
+	out := &bytes.Buffer{}
+	for n := 1; ; n++ {
+		var record json.RawMessage
+		err := dec.Decode(&record)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		for i := range paths {
+			record, err = t.jsonPath(record, paths[i])
+			if err != nil {
+				return fmt.Errorf("record %d, field %s: %w", n, t.Fields[i], err)
+			}
+		}
+		out.Reset()
+		if err := json.Compact(out, record); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		if _, err := w.Write(out.Bytes()); err != nil {
+			return err
+		}
+		recordsProcessed.WithLabelValues(JSONL).Inc()
+	}
+}
+
+// jsonMember is a member of a JSON object, in the order of the input.
+type jsonMember struct {
+	key string
+	// rawKey is the key as it is written in the input, with its quotes.
+	rawKey []byte
+	value  json.RawMessage
+}
+
+// jsonObject decodes the members of a JSON object, keeping their order.
+func jsonObject(v json.RawMessage) ([]jsonMember, error) {
+	dec := json.NewDecoder(bytes.NewReader(v))
+	if _, err := dec.Token(); err != nil { // The opening brace.
+		return nil, err
+	}
+	var members []jsonMember
+	for dec.More() {
+		start := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		// The input between the offsets also holds any whitespace and the comma
+		// before the key.
+		raw := bytes.TrimSpace(v[start:dec.InputOffset()])
+		raw = bytes.TrimSpace(bytes.TrimPrefix(raw, []byte(",")))
+		m := jsonMember{key: tok.(string), rawKey: raw}
+		if err := dec.Decode(&m.value); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// jsonPath anonymizes the addresses at path within v, and returns the updated
+// value. Parts of v that are not on the path are returned unchanged.
+func (t *Transformer) jsonPath(v json.RawMessage, path []string) (json.RawMessage, error) {
+	v = bytes.TrimSpace(v)
+	if len(v) == 0 {
+		return nil, fmt.Errorf("empty JSON value")
+	}
+	switch v[0] {
+	case 'n':
+		return v, nil
+	case '[':
+		var elems []json.RawMessage
+		if err := json.Unmarshal(v, &elems); err != nil {
+			return nil, err
+		}
+		// The array is written by hand, since json.Marshal would escape the
+		// HTML characters in values that are not on the path.
+		b := &bytes.Buffer{}
+		b.WriteByte('[')
+		for i := range elems {
+			elem, err := t.jsonPath(elems[i], path)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.Write(elem)
+		}
+		b.WriteByte(']')
+		return b.Bytes(), nil
+	case '{':
+		if len(path) == 0 {
+			return nil, fmt.Errorf("found an object where an address was expected")
+		}
+		members, err := jsonObject(v)
+		if err != nil {
+			return nil, err
+		}
+		changed := false
+		for i := range members {
+			// Duplicate keys are all anonymized, since readers differ in which
+			// one they use.
+			if members[i].key != path[0] {
+				continue
+			}
+			if members[i].value, err = t.jsonPath(members[i].value, path[1:]); err != nil {
+				return nil, err
+			}
+			changed = true
+		}
+		if !changed {
+			return v, nil
+		}
+		b := &bytes.Buffer{}
+		b.WriteByte('{')
+		for i, m := range members {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.Write(m.rawKey)
+			b.WriteByte(':')
+			b.Write(m.value)
+		}
+		b.WriteByte('}')
+		return b.Bytes(), nil
+	case '"':
+		if len(path) != 0 {
+			return nil, fmt.Errorf("found a string where an object was expected")
+		}
+		var s string
+		if err := json.Unmarshal(v, &s); err != nil {
+			return nil, err
+		}
+		a, err := t.anonymize(JSONL, s)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(a)
+	default:
+		return nil, fmt.Errorf("cannot anonymize the JSON value %s", v)
+	}
+}
+
+// CSV reads CSV records from r and writes them to w with every address in the
+// configured columns anonymized. The first record must be a header naming the
+// columns, and every configured field must appear in it.
+func (t *Transformer) CSV(r io.Reader, w io.Writer) error {
+	cr := csv.NewReader(r)
+	cw := csv.NewWriter(w)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	columns := make([]int, len(t.Fields))
+	for i, f := range t.Fields {
+		columns[i] = -1
+		for j, h := range header {
+			if h == f {
+				columns[i] = j
+				break
+			}
+		}
+		if columns[i] < 0 {
+			return fmt.Errorf("field %q is not in the CSV header %v", f, header)
+		}
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, c := range columns {
+			if record[c], err = t.anonymize(CSV, record[c]); err != nil {
+				line, _ := cr.FieldPos(c)
+				return fmt.Errorf("line %d, field %s: %w", line, t.Fields[i], err)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+		recordsProcessed.WithLabelValues(CSV).Inc()
+	}
+	cw.Flush()
+	return cw.Error()
+}
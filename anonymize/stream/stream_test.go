@@ -0,0 +1,188 @@
+package stream_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/m-lab/go/anonymize"
+	"github.com/m-lab/go/anonymize/stream"
+	"github.com/m-lab/go/prometheusx/promtest"
+	"github.com/m-lab/go/rtx"
+)
+
+func netblock() anonymize.IPAnonymizer {
+	anon, err := anonymize.NewFromConfig(anonymize.Config{
+		Method:     anonymize.Netblock,
+		IPv4Prefix: anonymize.DefaultIPv4Prefix,
+		IPv6Prefix: anonymize.DefaultIPv6Prefix,
+	})
+	rtx.Must(err, "Could not create anonymizer")
+	return anon
+}
+
+func TestTransformer_JSONL(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "top-level",
+			fields: []string{"ClientIP"},
+			input:  `{"ClientIP":"10.1.2.3","Bytes":12345678901234567890}` + "\n",
+			want:   `{"ClientIP":"10.1.2.0","Bytes":12345678901234567890}` + "\n",
+		},
+		{
+			name:   "preserves-order-and-values",
+			fields: []string{"Client.IP", "Hops.Addr"},
+			input: `{
+	"Zeta": 1.50, "Client": {"Port": 80, "IP": "10.1.2.3"},
+	"Alpha": "caf\u00e9 <&>", "Client": {"IP": "10.4.5.6"},
+	"Hops": [ {"Addr": "10.0.0.1", "Note": "<&>", "<k\u00e9y>": 1e3} , null ]
+}`,
+			want: `{"Zeta":1.50,"Client":{"Port":80,"IP":"10.1.2.0"},"Alpha":"caf\u00e9 <&>","Client":{"IP":"10.4.5.0"},` +
+				`"Hops":[{"Addr":"10.0.0.0","Note":"<&>","<k\u00e9y>":1e3},null]}` + "\n",
+		},
+		{
+			name:   "nested-and-arrays",
+			fields: []string{"Client.IP", "Hops.Addr"},
+			input: `{"Client":{"IP":"2001:db8:1:2::3"},"Hops":[{"Addr":"10.0.0.1"},{"Addr":null},{}]}
+{"Client":{"IP":""}}`,
+			want: `{"Client":{"IP":"2001:db8:1::"},"Hops":[{"Addr":"10.0.0.0"},{"Addr":null},{}]}
+{"Client":{"IP":""}}
+`,
+		},
+		{
+			name:   "array-of-addresses",
+			fields: []string{"IPs"},
+			input:  `{"IPs":["10.0.0.1","10.0.1.1"],"Note":"<&>"}`,
+			want:   `{"IPs":["10.0.0.0","10.0.1.0"],"Note":"<&>"}` + "\n",
+		},
+		{
+			name:   "missing-field",
+			fields: []string{"Server.IP"},
+			input:  `{"Client":"10.0.0.1"}`,
+			want:   `{"Client":"10.0.0.1"}` + "\n",
+		},
+		{
+			name:    "not-an-address",
+			fields:  []string{"ClientIP"},
+			input:   `{"ClientIP":"localhost"}`,
+			wantErr: true,
+		},
+		{
+			name:    "not-a-string",
+			fields:  []string{"ClientIP"},
+			input:   `{"ClientIP":17}`,
+			wantErr: true,
+		},
+		{
+			name:    "object-instead-of-address",
+			fields:  []string{"Client"},
+			input:   `{"Client":{"IP":"10.0.0.1"}}`,
+			wantErr: true,
+		},
+		{
+			name:    "address-instead-of-object",
+			fields:  []string{"Client.IP"},
+			input:   `{"Client":"10.0.0.1"}`,
+			wantErr: true,
+		},
+		{
+			name:    "bad-json",
+			fields:  []string{"Client"},
+			input:   `{"Client":`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := stream.New(netblock(), tt.fields...)
+			out := &bytes.Buffer{}
+			err := tr.Transform(stream.JSONL, strings.NewReader(tt.input), out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("JSONL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out.String() != tt.want {
+				t.Errorf("JSONL() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformer_CSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "success",
+			fields: []string{"client", "server"},
+			input:  "id,client,server\n1,10.1.2.3,2001:db8:1:2::3\n2,,10.0.0.1\n",
+			want:   "id,client,server\n1,10.1.2.0,2001:db8:1::\n2,,10.0.0.0\n",
+		},
+		{
+			name:  "empty",
+			input: "",
+			want:  "",
+		},
+		{
+			name:    "missing-column",
+			fields:  []string{"client"},
+			input:   "id,server\n1,10.0.0.1\n",
+			wantErr: true,
+		},
+		{
+			name:    "not-an-address",
+			fields:  []string{"client"},
+			input:   "id,client\n1,localhost\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad-header",
+			fields:  []string{"client"},
+			input:   "\"id,client\n",
+			wantErr: true,
+		},
+		{
+			name:    "bad-record",
+			fields:  []string{"client"},
+			input:   "id,client\n1,10.0.0.1,extra\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := stream.New(netblock(), tt.fields...)
+			out := &bytes.Buffer{}
+			err := tr.Transform(stream.CSV, strings.NewReader(tt.input), out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CSV() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && out.String() != tt.want {
+				t.Errorf("CSV() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformer_UnknownFormat(t *testing.T) {
+	err := stream.New(netblock()).Transform("xml", strings.NewReader(""), &bytes.Buffer{})
+	if !errors.Is(err, stream.ErrUnknownFormat) {
+		t.Errorf("Transform() = %v, want ErrUnknownFormat", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	tr := stream.New(netblock(), "ip")
+	rtx.Must(tr.CSV(strings.NewReader("ip\n10.0.0.1\n"), &bytes.Buffer{}), "Could not transform")
+	rtx.Must(tr.JSONL(strings.NewReader(`{"ip":"10.0.0.1"}`), &bytes.Buffer{}), "Could not transform")
+	promtest.LintMetrics(t)
+}
@@ -0,0 +1,87 @@
+// anonymize-archive reads JSONL or CSV records, anonymizes the IP addresses in
+// the named fields, and writes the result. It is intended for anonymizing
+// archives after the fact, using the same methods and flags as programs that
+// anonymize data as it is collected. Only the named fields are changed, but
+// JSONL records are written compacted, one per line. For example:
+//
+//	anonymize-archive -anonymize.ip=netblock -format=csv -fields=client_ip,server_ip \
+//	    -input=archive.csv -output=anonymized.csv
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/m-lab/go/anonymize"
+	"github.com/m-lab/go/anonymize/stream"
+	"github.com/m-lab/go/flagx"
+	"github.com/m-lab/go/rtx"
+)
+
+var (
+	format = flagx.Enum{
+		Options: []string{stream.JSONL, stream.CSV},
+		Value:   stream.JSONL,
+	}
+	fields flagx.StringArray
+	input  = flag.String("input", "", "File from which to read records. Defaults to stdin.")
+	output = flag.String("output", "", "File to which anonymized records are written. Defaults to stdout.")
+
+	// Allow overriding for testing.
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+	osExit           = os.Exit
+)
+
+func init() {
+	flag.Var(&format, "format", "Format of the records: \"jsonl\" or \"csv\". JSONL records keep their key order and values, but are written compacted, one per line.")
+	flag.Var(&fields, "fields", "Fields containing IP addresses. JSONL fields are dot-separated paths, CSV fields are column names. May be repeated or comma-separated.")
+}
+
+func main() {
+	flag.Parse()
+	rtx.Must(flagx.ArgsFromEnv(flag.CommandLine), "Could not get args from env")
+
+	// Copying the archive through unchanged would publish the raw addresses,
+	// so refuse to run without a method and fields.
+	config := anonymize.ConfigFromFlags()
+	if config.Method == anonymize.None {
+		fmt.Fprintln(stderr, "-anonymize.ip is required and must not be \"none\"")
+		osExit(2)
+		return
+	}
+	if len(fields) == 0 {
+		fmt.Fprintln(stderr, "-fields is required")
+		osExit(2)
+		return
+	}
+
+	anon, err := anonymize.NewFromConfig(config)
+	rtx.Must(err, "Could not create anonymizer")
+
+	r := stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		rtx.Must(err, "Could not open %q", *input)
+		defer f.Close()
+		r = f
+	}
+	w := stdout
+	var out *os.File
+	if *output != "" {
+		out, err = os.Create(*output)
+		rtx.Must(err, "Could not create %q", *output)
+		w = out
+	}
+
+	bw := bufio.NewWriter(w)
+	rtx.Must(stream.New(anon, fields...).Transform(format.Value, bufio.NewReader(r), bw), "Could not anonymize records")
+	rtx.Must(bw.Flush(), "Could not write records")
+	if out != nil {
+		rtx.Must(out.Close(), "Could not close %q", *output)
+	}
+}
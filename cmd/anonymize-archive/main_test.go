@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m-lab/go/rtx"
+)
+
+func TestMain_CSV(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.csv")
+	out := filepath.Join(dir, "out.csv")
+	rtx.Must(os.WriteFile(in, []byte("id,client\n1,10.1.2.3\n"), 0644), "Could not write input")
+
+	for k, v := range map[string]string{
+		"anonymize.ip": "netblock",
+		"format":       "csv",
+		"fields":       "client",
+		"input":        in,
+		"output":       out,
+	} {
+		rtx.Must(flag.Set(k, v), "Could not set flag %s", k)
+	}
+	defer func() {
+		fields = nil
+		*input = ""
+		*output = ""
+	}()
+	main()
+
+	b, err := os.ReadFile(out)
+	rtx.Must(err, "Could not read output")
+	if string(b) != "id,client\n1,10.1.2.0\n" {
+		t.Errorf("main() wrote %q", string(b))
+	}
+}
+
+func TestMain_JSONL(t *testing.T) {
+	for k, v := range map[string]string{
+		"anonymize.ip": "netblock",
+		"format":       "jsonl",
+		"fields":       "Client.IP",
+	} {
+		rtx.Must(flag.Set(k, v), "Could not set flag %s", k)
+	}
+	defer func() { fields = nil }()
+	buf := &bytes.Buffer{}
+	stdin = strings.NewReader(`{"Client":{"IP":"2001:db8:1:2::3"}}`)
+	stdout = buf
+	defer func() {
+		stdin = os.Stdin
+		stdout = os.Stdout
+	}()
+	main()
+
+	if buf.String() != `{"Client":{"IP":"2001:db8:1::"}}`+"\n" {
+		t.Errorf("main() wrote %q", buf.String())
+	}
+}
+
+func TestMain_FailsClosed(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		fields string
+	}{
+		{name: "no-method", method: "none", fields: "client"},
+		{name: "no-fields", method: "netblock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rtx.Must(flag.Set("anonymize.ip", tt.method), "Could not set method")
+			fields = nil
+			if tt.fields != "" {
+				rtx.Must(flag.Set("fields", tt.fields), "Could not set fields")
+			}
+			out := &bytes.Buffer{}
+			errs := &bytes.Buffer{}
+			code := -1
+			stdin = strings.NewReader(`{"client":"10.1.2.3"}`)
+			stdout = out
+			stderr = errs
+			osExit = func(c int) { code = c }
+			defer func() {
+				fields = nil
+				stdin = os.Stdin
+				stdout = os.Stdout
+				stderr = os.Stderr
+				osExit = os.Exit
+			}()
+			main()
+
+			if code != 2 || errs.Len() == 0 {
+				t.Errorf("main() exited with %d and stderr %q, want 2 and an error", code, errs.String())
+			}
+			if out.Len() != 0 {
+				t.Errorf("main() wrote %q, want nothing", out.String())
+			}
+		})
+	}
+}
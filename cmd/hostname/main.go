@@ -0,0 +1,102 @@
+// hostname parses M-Lab hostnames, and prints them as JSON or converts them
+// to another naming scheme. Names are read from the arguments or, if there
+// are none, one per line from stdin. Every name that cannot be parsed or
+// converted is reported on stderr, and makes hostname exit with a non-zero
+// status after all names are processed. For example:
+//
+//	hostname -format=v2 -project=mlab-oti mlab1.lga01.measurement-lab.org
+//	cat names.txt | hostname -check
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/m-lab/go/flagx"
+	"github.com/m-lab/go/host"
+	"github.com/m-lab/go/rtx"
+)
+
+var (
+	format = flagx.Enum{
+		Options: []string{"json", "v1", "v2", "v3", "string", "all"},
+		Value:   "json",
+	}
+	project = flag.String("project", "", "Project of names without one (e.g. v1 names), needed to convert them to v2 or v3.")
+	check   = flag.Bool("check", false, "Only check that names are valid, without printing them.")
+
+	// Allow overriding for testing.
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+	osExit           = os.Exit
+)
+
+func init() {
+	flag.Var(&format, "format", "Output format: \"json\", \"v1\", \"v2\", \"v3\", \"string\" (as host.Name.String) or \"all\" (with service and suffix).")
+}
+
+// convert parses the hostname and formats it as requested.
+func convert(hostname string) (string, error) {
+	n, err := host.Parse(hostname)
+	if err != nil {
+		return "", err
+	}
+	if n.Project == "" {
+		n.Project = *project
+	}
+	switch format.Value {
+	case "v1":
+		return n.V1()
+	case "v2":
+		return n.V2()
+	case "v3":
+		return n.V3()
+	case "string":
+		return n.String(), nil
+	case "all":
+		return n.StringAll(), nil
+	default:
+		b, err := json.Marshal(n)
+		return string(b), err
+	}
+}
+
+func main() {
+	flag.Parse()
+	rtx.Must(flagx.ArgsFromEnvWithLog(flag.CommandLine, false), "Could not get args from env")
+
+	names := flag.Args()
+	if len(names) == 0 {
+		s := bufio.NewScanner(stdin)
+		for s.Scan() {
+			if name := strings.TrimSpace(s.Text()); name != "" {
+				names = append(names, name)
+			}
+		}
+		rtx.Must(s.Err(), "Could not read names")
+	}
+
+	w := bufio.NewWriter(stdout)
+	failed := 0
+	for _, name := range names {
+		out, err := convert(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			failed++
+			continue
+		}
+		if !*check {
+			fmt.Fprintln(w, out)
+		}
+	}
+	rtx.Must(w.Flush(), "Could not write names")
+	if failed > 0 {
+		osExit(1)
+	}
+}
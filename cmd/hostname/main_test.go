@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/m-lab/go/rtx"
+)
+
+func TestMain_Formats(t *testing.T) {
+	tests := []struct {
+		name       string
+		flags      map[string]string
+		args       []string
+		stdin      string
+		wantStdout string
+		wantStderr string
+		wantExit   bool
+	}{
+		{
+			name:       "json-from-args",
+			args:       []string{"ndt-mlab1-lga01.mlab-oti.measurement-lab.org-d9h6"},
+			wantStdout: `{"Service":"ndt","Machine":"mlab1","Site":"lga01","Project":"mlab-oti","Org":"mlab","Domain":"measurement-lab.org","Suffix":"-d9h6","Version":"v2"}` + "\n",
+		},
+		{
+			name:       "v2-from-stdin",
+			flags:      map[string]string{"format": "v2", "project": "mlab-oti"},
+			stdin:      "mlab1.lga01.measurement-lab.org\n\n  mlab2-lga01.mlab-staging.measurement-lab.org  \n",
+			wantStdout: "mlab1-lga01.mlab-oti.measurement-lab.org\nmlab2-lga01.mlab-staging.measurement-lab.org\n",
+		},
+		{
+			name:       "v1",
+			flags:      map[string]string{"format": "v1"},
+			args:       []string{"mlab1-lga01.mlab-oti.measurement-lab.org"},
+			wantStdout: "mlab1.lga01.measurement-lab.org\n",
+		},
+		{
+			name:       "v3",
+			flags:      map[string]string{"format": "v3"},
+			args:       []string{"ndt-lga3356-c0a80001.mlab.autojoin.measurement-lab.org"},
+			wantStdout: "ndt-lga3356-c0a80001.mlab.autojoin.measurement-lab.org\n",
+		},
+		{
+			name:       "string",
+			flags:      map[string]string{"format": "string"},
+			args:       []string{"ndt-mlab1-lga01.mlab-oti.measurement-lab.org-d9h6"},
+			wantStdout: "mlab1-lga01.mlab-oti.measurement-lab.org\n",
+		},
+		{
+			name:       "all",
+			flags:      map[string]string{"format": "all"},
+			args:       []string{"ndt-mlab1-lga01.mlab-oti.measurement-lab.org-d9h6"},
+			wantStdout: "ndt-mlab1-lga01.mlab-oti.measurement-lab.org-d9h6\n",
+		},
+		{
+			name:       "check-reports-invalid-names",
+			flags:      map[string]string{"check": "true"},
+			args:       []string{"mlab1.lga01.measurement-lab.org", "mlab1.lga01.example.com"},
+			wantStderr: "mlab1.lga01.example.com: invalid domain: mlab1.lga01.example.com\n",
+			wantExit:   true,
+		},
+		{
+			name:       "unconvertible",
+			flags:      map[string]string{"format": "v2"},
+			args:       []string{"mlab1.lga01.measurement-lab.org", "mlab1-lga01.mlab-oti.measurement-lab.org"},
+			wantStdout: "mlab1-lga01.mlab-oti.measurement-lab.org\n",
+			wantStderr: "mlab1.lga01.measurement-lab.org: cannot format",
+			wantExit:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.flags {
+				rtx.Must(flag.Set(k, v), "Could not set flag %s", k)
+			}
+			rtx.Must(flag.CommandLine.Parse(tt.args), "Could not parse args")
+			out, errs := &bytes.Buffer{}, &bytes.Buffer{}
+			stdin = strings.NewReader(tt.stdin)
+			stdout = out
+			stderr = errs
+			exited := false
+			osExit = func(int) { exited = true }
+			defer func() {
+				stdin = os.Stdin
+				stdout = os.Stdout
+				stderr = os.Stderr
+				osExit = os.Exit
+				format.Value = "json"
+				*project = ""
+				*check = false
+			}()
+			// main() parses the flags again, so put the args back in place.
+			os.Args = append([]string{"hostname"}, tt.args...)
+			main()
+
+			if out.String() != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", out.String(), tt.wantStdout)
+			}
+			if !strings.HasPrefix(errs.String(), tt.wantStderr) || (tt.wantStderr == "") != (errs.Len() == 0) {
+				t.Errorf("stderr = %q, want %q", errs.String(), tt.wantStderr)
+			}
+			if exited != tt.wantExit {
+				t.Errorf("exited = %v, want %v", exited, tt.wantExit)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// siteinfo-diff validates siteinfo data and prints, as JSON, the machines that
+// were added, removed or changed since an older copy of it. Locations are local
+// directories or content URLs with the layout of a siteinfo version directory,
+// e.g. sites/machines.json. Problems found by validation are reported on
+// stderr, and make siteinfo-diff exit with a non-zero status, as do removed or
+// changed machines with -fail-on-change. For example:
+//
+//	siteinfo-diff -old=gs://bucket/v2/ -new=./output/v2/ -fail-on-change
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/m-lab/go/flagx"
+	"github.com/m-lab/go/rtx"
+	"github.com/m-lab/go/siteinfo"
+)
+
+var (
+	oldLocation  = flag.String("old", "", "Location of the current siteinfo data. If empty, -new is only validated.")
+	newLocation  = flag.String("new", "", "Location of the siteinfo data about to be rolled out.")
+	failOnChange = flag.Bool("fail-on-change", false, "Exit with a non-zero status if machines were removed or changed.")
+
+	// Allow overriding for testing.
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+	osExit           = os.Exit
+)
+
+// load validates the siteinfo data at location, reports its problems, and
+// returns its machines along with the number of problems.
+func load(ctx context.Context, location string) ([]siteinfo.Machine, int) {
+	c, err := siteinfo.NewFromURL(ctx, location)
+	rtx.Must(err, "Could not read siteinfo from %q", location)
+	problems, err := c.ValidateContext(ctx)
+	rtx.Must(err, "Could not validate %q", location)
+	for _, p := range problems {
+		fmt.Fprintf(stderr, "%s: %s\n", location, p)
+	}
+	machines, err := c.MachinesContext(ctx)
+	rtx.Must(err, "Could not get machines from %q", location)
+	return machines, len(problems)
+}
+
+func main() {
+	flag.Parse()
+	rtx.Must(flagx.ArgsFromEnvWithLog(flag.CommandLine, false), "Could not get args from env")
+	if *newLocation == "" {
+		fmt.Fprintln(stderr, "-new is required")
+		osExit(2)
+		return
+	}
+
+	ctx := context.Background()
+	newMachines, failed := load(ctx, *newLocation)
+	if *oldLocation != "" {
+		oldMachines, _ := load(ctx, *oldLocation)
+		d := siteinfo.DiffMachines(oldMachines, newMachines)
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		rtx.Must(enc.Encode(d), "Could not write diff")
+		if *failOnChange && d.Breaking() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		osExit(1)
+	}
+}
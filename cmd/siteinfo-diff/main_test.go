@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/m-lab/go/rtx"
+	"github.com/m-lab/go/siteinfo"
+)
+
+// writeSiteinfo returns a directory with the given siteinfo machines and
+// switches.
+func writeSiteinfo(t *testing.T, machines, switches string) string {
+	dir := t.TempDir()
+	rtx.Must(os.Mkdir(filepath.Join(dir, "sites"), 0755), "Could not make sites dir")
+	rtx.Must(os.WriteFile(filepath.Join(dir, "sites", "machines.json"), []byte(machines), 0644), "Could not write machines")
+	rtx.Must(os.WriteFile(filepath.Join(dir, "sites", "switches.json"), []byte(switches), 0644), "Could not write switches")
+	return dir
+}
+
+func TestMain_Diff(t *testing.T) {
+	const switches = `{"lga01": {"ipv4_prefix": "192.168.0.0/26"}}`
+	current := writeSiteinfo(t, `[
+		{"hostname": "mlab1-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.1", "project": "mlab-oti", "type": "physical"},
+		{"hostname": "mlab2-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.2", "project": "mlab-oti", "type": "physical"}
+	]`, switches)
+	added := writeSiteinfo(t, `[
+		{"hostname": "mlab1-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.1", "project": "mlab-oti", "type": "physical"},
+		{"hostname": "mlab2-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.2", "project": "mlab-oti", "type": "physical"},
+		{"hostname": "mlab3-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.3", "project": "mlab-oti", "type": "physical"}
+	]`, switches)
+	changed := writeSiteinfo(t, `[
+		{"hostname": "mlab1-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.9", "project": "mlab-oti", "type": "physical"}
+	]`, switches)
+	invalid := writeSiteinfo(t, `[
+		{"hostname": "mlab1-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.1", "project": "mlab-oti", "type": ""}
+	]`, switches)
+
+	tests := []struct {
+		name        string
+		flags       map[string]string
+		wantAdded   int
+		wantRemoved int
+		wantChanged int
+		wantStderr  string
+		wantExit    bool
+	}{
+		{
+			name:      "added",
+			flags:     map[string]string{"old": current, "new": added, "fail-on-change": "true"},
+			wantAdded: 1,
+		},
+		{
+			name:        "changed",
+			flags:       map[string]string{"old": current, "new": changed},
+			wantRemoved: 1,
+			wantChanged: 1,
+		},
+		{
+			name:        "fail-on-change",
+			flags:       map[string]string{"old": current, "new": changed, "fail-on-change": "true"},
+			wantRemoved: 1,
+			wantChanged: 1,
+			wantExit:    true,
+		},
+		{
+			name:       "validate-only",
+			flags:      map[string]string{"old": "", "new": invalid},
+			wantStderr: invalid + ": sites/machines.json: mlab1-lga01.mlab-oti.measurement-lab.org: type: missing\n",
+			wantExit:   true,
+		},
+		{
+			name:       "missing-new",
+			flags:      map[string]string{"new": ""},
+			wantStderr: "-new is required\n",
+			wantExit:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.flags {
+				rtx.Must(flag.Set(k, v), "Could not set flag %s", k)
+			}
+			outBuf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}
+			stdout, stderr = outBuf, errBuf
+			exited := false
+			osExit = func(int) { exited = true }
+			defer func() {
+				*oldLocation, *newLocation, *failOnChange = "", "", false
+				stdout, stderr, osExit = os.Stdout, os.Stderr, os.Exit
+			}()
+			main()
+
+			if *oldLocation != "" {
+				var d siteinfo.Diff
+				rtx.Must(json.NewDecoder(strings.NewReader(outBuf.String())).Decode(&d), "Could not decode diff")
+				if len(d.Added) != tt.wantAdded || len(d.Removed) != tt.wantRemoved || len(d.Changed) != tt.wantChanged {
+					t.Errorf("main() diff = %+v", d)
+				}
+			}
+			if errBuf.String() != tt.wantStderr {
+				t.Errorf("main() stderr = %q, want %q", errBuf.String(), tt.wantStderr)
+			}
+			if exited != tt.wantExit {
+				t.Errorf("main() exited = %v, want %v", exited, tt.wantExit)
+			}
+		})
+	}
+}
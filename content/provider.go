@@ -4,14 +4,18 @@ package content
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -23,16 +27,52 @@ import (
 var (
 	ErrUnsupportedURLScheme = errors.New("Unsupported URL scheme")
 	ErrNoChange             = errors.New("Data is unchanged")
+	ErrTooLarge             = errors.New("Data is too large")
 )
 
+// MaxSize is the largest file, in bytes, that the Providers returned by
+// FromURL read. Get returns ErrTooLarge for larger files.
+var MaxSize int64 = 1 << 30
+
+// StatusError is returned by the Providers for http, https and s3 URLs when
+// the server responds with an unexpected HTTP status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d (%s)", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
 // Provider is the interface implemented by everything that can return raw files.
 type Provider interface {
 	// Get returns the raw file []byte read from the latest copy of the provider
 	// URL. It may be called multiple times. Caching is left up to the individual
 	// Provider implementation.
+	//
+	// The Providers returned by FromURL return ErrNoChange instead of the file
+	// if it did not change since the last successful call to Get. The first
+	// successful call always returns the file, and failed calls do not change
+	// what the next call compares the file to. Changes are detected with the
+	// MD5 of gs objects, the modification time of local files, and the ETag or
+	// Last-Modified headers of http, https and s3 responses, falling back to
+	// the SHA-256 of their bodies if the server sends neither header.
 	Get(ctx context.Context) ([]byte, error)
 }
 
+// readAll reads r, failing with ErrTooLarge if it is larger than MaxSize.
+func readAll(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > MaxSize {
+		return nil, ErrTooLarge
+	}
+	return b, nil
+}
+
 // gcsProvider gets zip files from Google Cloud Storage.
 type gcsProvider struct {
 	bucket, filename string
@@ -49,6 +89,9 @@ func (g *gcsProvider) Get(ctx context.Context) ([]byte, error) {
 	if g.md5 != nil && bytes.Equal(g.md5, oa.MD5) {
 		return nil, ErrNoChange
 	}
+	if oa.Size > MaxSize {
+		return nil, ErrTooLarge
+	}
 
 	// Otherise, we know that either g.md5 == nil || g.md5 != oa.MD5.
 	// Reload data only if the object changed or the data was never loaded in the first place.
@@ -56,7 +99,7 @@ func (g *gcsProvider) Get(ctx context.Context) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	data, err := ioutil.ReadAll(r)
+	data, err := readAll(r)
 	if err != nil {
 		return nil, err
 	}
@@ -83,6 +126,9 @@ func (f *fileProvider) Get(ctx context.Context) ([]byte, error) {
 	if newtime == f.mtime {
 		return nil, ErrNoChange
 	}
+	if s.Size() > MaxSize {
+		return nil, ErrTooLarge
+	}
 	b, err := ioutil.ReadFile(f.filename)
 	if err != nil {
 		return nil, err
@@ -91,11 +137,13 @@ func (f *fileProvider) Get(ctx context.Context) ([]byte, error) {
 	return b, nil
 }
 
-// httpsProvider gets files from public HTTPS URLs (i.e. no authentication).
+// httpsProvider gets files from public HTTP or HTTPS URLs (i.e. no
+// authentication).
 type httpsProvider struct {
-	u       url.URL
-	timeout time.Duration
-	client  *http.Client
+	u          url.URL
+	timeout    time.Duration
+	client     *http.Client
+	validators validators
 }
 
 func (h *httpsProvider) Get(ctx context.Context) ([]byte, error) {
@@ -105,55 +153,193 @@ func (h *httpsProvider) Get(ctx context.Context) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	resp, err := h.client.Do(r)
+	return h.validators.do(h.client, r)
+}
+
+// validators are the ETag and Last-Modified headers, and the SHA-256 of the
+// body, of the last successful response for a URL.
+type validators struct {
+	etag, lastModified string
+	sum                []byte
+}
+
+// do sends the request, conditional on the validators, and returns the body of
+// a successful response. It returns ErrNoChange if the server responds that
+// the file is not modified or, for servers that send neither ETag nor
+// Last-Modified headers, if the body is the same as the last one. It returns a
+// *StatusError for other non-2xx responses.
+func (v *validators) do(client *http.Client, r *http.Request) ([]byte, error) {
+	if v.etag != "" {
+		r.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		r.Header.Set("If-Modified-Since", v.lastModified)
+	}
+	resp, err := client.Do(r)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	return ioutil.ReadAll(resp.Body)
+	switch {
+	case resp.StatusCode == http.StatusNotModified && (v.etag != "" || v.lastModified != ""):
+		return nil, ErrNoChange
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, &StatusError{URL: r.URL.Redacted(), StatusCode: resp.StatusCode}
+	case resp.ContentLength > MaxSize:
+		return nil, ErrTooLarge
+	}
+	b, err := readAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	v.etag = resp.Header.Get("ETag")
+	v.lastModified = resp.Header.Get("Last-Modified")
+	sum := sha256.Sum256(b)
+	if v.sum != nil && bytes.Equal(v.sum, sum[:]) {
+		return nil, ErrNoChange
+	}
+	v.sum = sum[:]
+	return b, nil
+}
+
+// dataProvider returns the data embedded in a data: URL.
+type dataProvider struct {
+	data []byte
+	read bool
+}
+
+func (d *dataProvider) Get(ctx context.Context) ([]byte, error) {
+	if d.read {
+		return nil, ErrNoChange
+	}
+	d.read = true
+	return d.data, nil
+}
+
+// parseDataURL returns the data of a data: URL, as described in RFC 2397, e.g.
+// data:,hello or data:application/json;base64,e30=.
+func parseDataURL(u *url.URL) ([]byte, error) {
+	s := u.Opaque
+	if s == "" {
+		// A data URL whose media type starts with a slash, which is not valid.
+		return nil, errors.New("Bad data url, no data detected")
+	}
+	comma := strings.IndexByte(s, ',')
+	if comma < 0 {
+		return nil, errors.New("Bad data url, no comma detected")
+	}
+	meta, data := s[:comma], s[comma+1:]
+	if u.RawQuery != "" {
+		data += "?" + u.RawQuery
+	}
+	unescaped, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(unescaped)
+	}
+	return []byte(unescaped), nil
+}
+
+// Opener returns a Provider for a URL with the scheme it is registered for.
+type Opener func(ctx context.Context, u *url.URL) (Provider, error)
+
+var (
+	openersMu sync.RWMutex
+	openers   = map[string]Opener{}
+)
+
+// Register makes FromURL use open for URLs with the given scheme, so that
+// applications can support their own schemes. Schemes are case insensitive.
+// Registering a scheme again replaces its Opener, including for the schemes
+// supported by this package. It panics if the scheme is empty or open is nil.
+func Register(scheme string, open Opener) {
+	scheme = strings.ToLower(scheme)
+	if scheme == "" || open == nil {
+		panic("content: Register needs a scheme and an Opener")
+	}
+	openersMu.Lock()
+	defer openersMu.Unlock()
+	openers[scheme] = open
+}
+
+func init() {
+	Register("gs", openGCS)
+	Register("file", openFile)
+	Register("http", openHTTP)
+	Register("https", openHTTP)
+	Register("data", openData)
+	Register("s3", openS3)
+}
+
+func openGCS(ctx context.Context, u *url.URL) (Provider, error) {
+	client, err := storage.NewClient(ctx)
+	filename := strings.TrimPrefix(u.Path, "/")
+	if len(filename) == 0 {
+		return nil, errors.New("Bad GS url, no filename detected")
+	}
+	return &gcsProvider{
+		client:   stiface.AdaptClient(client),
+		bucket:   u.Host,
+		filename: filename,
+	}, err
+}
+
+func openFile(ctx context.Context, u *url.URL) (Provider, error) {
+	if u.Path == "" {
+		return &fileProvider{
+			filename: u.Opaque,
+		}, nil
+	}
+	return &fileProvider{
+		filename: u.Path,
+	}, nil
+}
+
+func openHTTP(ctx context.Context, u *url.URL) (Provider, error) {
+	return &httpsProvider{
+		u:       *u,
+		timeout: time.Minute,
+		client:  http.DefaultClient,
+	}, nil
+}
+
+func openData(ctx context.Context, u *url.URL) (Provider, error) {
+	data, err := parseDataURL(u)
+	if err != nil {
+		return nil, err
+	}
+	return &dataProvider{data: data}, nil
 }
 
 // FromURL returns a new rawfile.Provider based on the passed-in URL. Supported
-// URL schemes are currently: gs://bucket/filename, file:localpath, and
-// https://. Whether the path contained in the URL is valid isn't known until
-// the Get() method of the returned Provider is called. Unsupported URL schemes
-// cause this to return ErrUnsupportedURLScheme.
+// URL schemes are currently: gs://bucket/filename, file:localpath, http://,
+// https://, data: (RFC 2397) and s3://bucket/key, along with any scheme added
+// with Register. Whether the path contained in the URL is valid isn't known
+// until the Get() method of the returned Provider is called. Unsupported URL
+// schemes cause this to return ErrUnsupportedURLScheme.
+//
+// s3:// URLs are read from any S3-compatible service. The endpoint and region
+// are taken from the "endpoint" and "region" query parameters if present (e.g.
+// s3://bucket/key?endpoint=http://localhost:9000), or else from the
+// AWS_ENDPOINT_URL_S3, AWS_ENDPOINT_URL, AWS_REGION and AWS_DEFAULT_REGION
+// environment variables, and default to https://s3.amazonaws.com and
+// us-east-1. Requests are signed with the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables if they are
+// set, and are anonymous otherwise.
 //
 // Users interested in having the daemon download the data directly from MaxMind
-// using credentials should implement an alternate https case in the below
-// handler. M-Lab doesn't need that case because we cache MaxMind's data to
-// reduce load on their servers and to eliminate a runtime dependency on a third
-// party service.
+// using credentials should implement an alternate https case with Register.
+// M-Lab doesn't need that case because we cache MaxMind's data to reduce load
+// on their servers and to eliminate a runtime dependency on a third party
+// service.
 func FromURL(ctx context.Context, u *url.URL) (Provider, error) {
-	switch u.Scheme {
-	case "gs":
-		client, err := storage.NewClient(ctx)
-		filename := strings.TrimPrefix(u.Path, "/")
-		if len(filename) == 0 {
-			return nil, errors.New("Bad GS url, no filename detected")
-		}
-		return &gcsProvider{
-			client:   stiface.AdaptClient(client),
-			bucket:   u.Host,
-			filename: filename,
-		}, err
-	case "file":
-		if u.Path == "" {
-			return &fileProvider{
-				filename: u.Opaque,
-			}, nil
-		}
-		return &fileProvider{
-			filename: u.Path,
-		}, nil
-
-	case "https":
-		return &httpsProvider{
-			u:       *u,
-			timeout: time.Minute,
-			client:  http.DefaultClient,
-		}, nil
-	default:
+	openersMu.RLock()
+	open, ok := openers[strings.ToLower(u.Scheme)]
+	openersMu.RUnlock()
+	if !ok {
 		return nil, ErrUnsupportedURLScheme
 	}
+	return open(ctx, u)
 }
@@ -12,6 +12,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -97,6 +98,18 @@ func TestFromURL(t *testing.T) {
 			name: "HTTPS file",
 			url:  "https://siteinfo.mlab-oti.measurementlab.net/v1/sites/annotations.json",
 		},
+		{
+			name: "HTTP file",
+			url:  "http://localhost:8080/sites/annotations.json",
+		},
+		{
+			name: "S3 file",
+			url:  "s3://mlab-nonexistent-bucket/nonexistent-object.zip",
+		},
+		{
+			name: "Data",
+			url:  "data:,hello",
+		},
 		{
 			name:    "Unsupported URL scheme",
 			url:     "gopher://gopher.floodgap.com/1/world",
@@ -423,3 +436,293 @@ func Test_httpsProvider_Get(t *testing.T) {
 		})
 	}
 }
+
+func TestDataFromURLThenGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "plain",
+			url:  "data:,hello%20world?x",
+			want: "hello world?x",
+		},
+		{
+			name: "media-type",
+			url:  "data:application/json,%7B%22a%22:1%7D",
+			want: `{"a":1}`,
+		},
+		{
+			name: "base64",
+			url:  "data:application/json;base64,eyJhIjoxfQ==",
+			want: `{"a":1}`,
+		},
+		{
+			name: "empty",
+			url:  "data:,",
+			want: "",
+		},
+		{
+			name:    "no-comma",
+			url:     "data:hello",
+			wantErr: true,
+		},
+		{
+			name:    "no-data",
+			url:     "data:/hello",
+			wantErr: true,
+		},
+		{
+			name:    "bad-escape",
+			url:     "data:,%zz",
+			wantErr: true,
+		},
+		{
+			name:    "bad-base64",
+			url:     "data:;base64,!!!",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// url.Parse rejects bad escapes, so build those URLs directly.
+			u := &url.URL{Scheme: "data", Opaque: strings.TrimPrefix(tt.url, "data:")}
+			if i := strings.IndexByte(u.Opaque, '?'); i >= 0 {
+				u.Opaque, u.RawQuery = u.Opaque[:i], u.Opaque[i+1:]
+			}
+			p, err := FromURL(context.Background(), u)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := p.Get(context.Background())
+			rtx.Must(err, "Could not get data")
+			if string(got) != tt.want {
+				t.Errorf("Get() = %q, want %q", got, tt.want)
+			}
+			if _, err = p.Get(context.Background()); err != ErrNoChange {
+				t.Error("Should have had ErrNoChange, but instead got", err)
+			}
+		})
+	}
+}
+
+type fakeProvider struct {
+	u *url.URL
+}
+
+func (f *fakeProvider) Get(ctx context.Context) ([]byte, error) {
+	return []byte(f.u.Opaque), nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("Test-Scheme", func(ctx context.Context, u *url.URL) (Provider, error) {
+		return &fakeProvider{u: u}, nil
+	})
+	u, err := url.Parse("test-scheme:hello")
+	rtx.Must(err, "Could not parse URL")
+	p, err := FromURL(context.Background(), u)
+	rtx.Must(err, "Could not create provider")
+	got, err := p.Get(context.Background())
+	rtx.Must(err, "Could not get data")
+	if string(got) != "hello" {
+		t.Errorf("Get() = %q, want hello", got)
+	}
+
+	// Registering a scheme again replaces it.
+	Register("test-scheme", func(ctx context.Context, u *url.URL) (Provider, error) {
+		return nil, ErrUnsupportedURLScheme
+	})
+	if _, err := FromURL(context.Background(), u); err != ErrUnsupportedURLScheme {
+		t.Errorf("FromURL() error = %v, want ErrUnsupportedURLScheme", err)
+	}
+
+	for _, tt := range []struct {
+		scheme string
+		open   Opener
+	}{
+		{scheme: "", open: openData},
+		{scheme: "test-scheme", open: nil},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) should have panicked", tt.scheme)
+				}
+			}()
+			Register(tt.scheme, tt.open)
+		}()
+	}
+}
+
+func Test_httpsProvider_Changes(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		cond   string
+	}{
+		{name: "etag", header: "ETag", value: `"v1"`, cond: "If-None-Match"},
+		{name: "last-modified", header: "Last-Modified", value: "Mon, 02 Jan 2006 15:04:05 GMT", cond: "If-Modified-Since"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := "v1"
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(tt.cond) == tt.value {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set(tt.header, tt.value)
+				io.WriteString(w, body)
+			}))
+			defer srv.Close()
+			u, err := url.Parse(srv.URL)
+			rtx.Must(err, "Could not parse URL")
+			p, err := FromURL(context.Background(), u)
+			rtx.Must(err, "Could not create provider")
+
+			got, err := p.Get(context.Background())
+			rtx.Must(err, "Could not get file")
+			if string(got) != "v1" {
+				t.Errorf("Get() = %q, want v1", got)
+			}
+			if _, err = p.Get(context.Background()); err != ErrNoChange {
+				t.Error("Should have had ErrNoChange, but instead got", err)
+			}
+			// A changed file has new validators.
+			tt.value = "changed"
+			body = "v2"
+			got, err = p.Get(context.Background())
+			rtx.Must(err, "Could not get changed file")
+			if string(got) != "v2" {
+				t.Errorf("Get() = %q, want v2", got)
+			}
+		})
+	}
+}
+
+func Test_httpsProvider_ChangesWithoutValidators(t *testing.T) {
+	body := "v1"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	rtx.Must(err, "Could not parse URL")
+	p, err := FromURL(context.Background(), u)
+	rtx.Must(err, "Could not create provider")
+
+	got, err := p.Get(context.Background())
+	rtx.Must(err, "Could not get file")
+	if string(got) != "v1" {
+		t.Errorf("Get() = %q, want v1", got)
+	}
+	if _, err = p.Get(context.Background()); err != ErrNoChange {
+		t.Error("Should have had ErrNoChange, but instead got", err)
+	}
+	body = "v2"
+	got, err = p.Get(context.Background())
+	rtx.Must(err, "Could not get changed file")
+	if string(got) != "v2" {
+		t.Errorf("Get() = %q, want v2", got)
+	}
+}
+
+func Test_httpsProvider_Errors(t *testing.T) {
+	defer func(size int64) { MaxSize = size }(MaxSize)
+	MaxSize = 4
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+		case "/not-modified":
+			// Not modified, although the request was not conditional.
+			w.WriteHeader(http.StatusNotModified)
+		case "/large":
+			io.WriteString(w, "too large")
+		case "/large-chunked":
+			io.WriteString(w, "too ")
+			w.(http.Flusher).Flush()
+			io.WriteString(w, "large")
+		default:
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer srv.Close()
+	tests := []struct {
+		path    string
+		wantErr error
+		status  int
+	}{
+		{path: "/ok"},
+		{path: "/missing", status: http.StatusNotFound},
+		{path: "/not-modified", status: http.StatusNotModified},
+		{path: "/large", wantErr: ErrTooLarge},
+		{path: "/large-chunked", wantErr: ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			u, err := url.Parse(srv.URL + tt.path)
+			rtx.Must(err, "Could not parse URL")
+			p, err := FromURL(context.Background(), u)
+			rtx.Must(err, "Could not create provider")
+			_, err = p.Get(context.Background())
+			var se *StatusError
+			switch {
+			case tt.status != 0:
+				if !errors.As(err, &se) || se.StatusCode != tt.status {
+					t.Errorf("Get() error = %v, want status %d", err, tt.status)
+				}
+			case err != tt.wantErr:
+				t.Errorf("Get() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	defer func(size int64) { MaxSize = size }(MaxSize)
+	MaxSize = 4
+
+	f, err := ioutil.TempFile(t.TempDir(), "")
+	rtx.Must(err, "Could not create tempfile")
+	_, err = f.WriteString("too large")
+	rtx.Must(err, "Could not write tempfile")
+	rtx.Must(f.Close(), "Could not close tempfile")
+	fp := &fileProvider{filename: f.Name()}
+	if _, err := fp.Get(context.Background()); err != ErrTooLarge {
+		t.Errorf("fileProvider.Get() error = %v, want ErrTooLarge", err)
+	}
+
+	g := &gcsProvider{
+		client: &fakeClient{
+			bh: &fakeBucketHandle{
+				oh: &fakeObjectHandle{
+					attrs:  &storage.ObjectAttrs{MD5: []byte("a hash"), Size: 9},
+					reader: &stifaceReaderThatsJustAnIOReader{r: bytes.NewBufferString("too large")},
+				},
+			},
+		},
+	}
+	if _, err := g.Get(context.Background()); err != ErrTooLarge {
+		t.Errorf("gcsProvider.Get() error = %v, want ErrTooLarge", err)
+	}
+	// An object larger than its attributes say.
+	g.client.(*fakeClient).bh.(*fakeBucketHandle).oh.(*fakeObjectHandle).attrs.Size = 4
+	if _, err := g.Get(context.Background()); err != ErrTooLarge {
+		t.Errorf("gcsProvider.Get() error = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	err := &StatusError{URL: "https://example.com/x", StatusCode: http.StatusNotFound}
+	want := "https://example.com/x returned status 404 (Not Found)"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
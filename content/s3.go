@@ -0,0 +1,174 @@
+package content
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptySHA256 is the hex SHA-256 of an empty payload, which is the payload of
+// every request made by s3Provider.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Provider gets objects from an S3-compatible service, using path-style
+// requests (i.e. endpoint/bucket/key) signed with AWS Signature Version 4.
+type s3Provider struct {
+	endpoint    url.URL
+	bucket, key string
+	region      string
+	// accessKey and secretKey are empty for anonymous requests.
+	accessKey, secretKey, sessionToken string
+	timeout                            time.Duration
+	client                             *http.Client
+	validators                         validators
+}
+
+func openS3(ctx context.Context, u *url.URL) (Provider, error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, errors.New("Bad S3 url, no bucket or key detected")
+	}
+	q := u.Query()
+	endpoint := firstNonEmpty(q.Get("endpoint"), os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"), "https://s3.amazonaws.com")
+	e, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if e.Scheme != "http" && e.Scheme != "https" || e.Host == "" {
+		return nil, fmt.Errorf("Bad S3 endpoint %q", endpoint)
+	}
+	return &s3Provider{
+		endpoint:     *e,
+		bucket:       u.Host,
+		key:          key,
+		region:       firstNonEmpty(q.Get("region"), os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1"),
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		timeout:      time.Minute,
+		client:       http.DefaultClient,
+	}, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (s *s3Provider) Get(ctx context.Context) ([]byte, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	u := s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket + "/" + s.key
+	// Send the path exactly as it is signed.
+	u.RawPath = s3Escape(u.Path)
+	u.RawQuery = ""
+	r, err := http.NewRequestWithContext(reqCtx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.accessKey != "" {
+		if s.sessionToken != "" {
+			r.Header.Set("X-Amz-Security-Token", s.sessionToken)
+		}
+		signV4(r, s.region, s.accessKey, s.secretKey, time.Now())
+	}
+	// The conditional headers are added after signing, since they do not need
+	// to be signed.
+	return s.validators.do(s.client, r)
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header for the S3
+// service to a request without a body. Every header already set on the
+// request, along with the Host, X-Amz-Date and X-Amz-Content-Sha256 headers,
+// is signed.
+func signV4(r *http.Request, region, accessKey, secretKey string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	r.Header.Set("X-Amz-Date", amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+
+	headers := map[string]string{"host": r.URL.Host}
+	for k, v := range r.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		s3Escape(r.URL.Path),
+		canonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + secretKey)
+	for _, s := range []string{date, region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	r.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// s3Escape percent-encodes every byte of s except unreserved characters and
+// slashes, as AWS Signature Version 4 requires for S3 paths.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery returns the query parameters sorted and encoded as AWS
+// Signature Version 4 requires.
+func canonicalQuery(q url.Values) string {
+	var params []string
+	for k, vs := range q {
+		for _, v := range vs {
+			params = append(params, strings.ReplaceAll(s3Escape(k), "/", "%2F")+"="+strings.ReplaceAll(s3Escape(v), "/", "%2F"))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
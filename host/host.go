@@ -24,17 +24,20 @@ type Name struct {
 
 // Parse parses an M-Lab hostname and breaks it into its constituent parts.
 // Parse also accepts service names and discards the service portion of the name.
+// Parse accepts the domains and grammars registered in the DefaultRegistry.
 func Parse(name string) (Name, error) {
+	return DefaultRegistry.Parse(name)
+}
+
+// Parse parses a hostname like the package-level Parse, accepting the domains
+// and grammars registered in r.
+func (r *Registry) Parse(name string) (Name, error) {
 	var parts Name
 	var err error
 
-	if name == "third-party" {
-		// Unconditionally return a Name for third-party origins.
-		return Name{
-			Machine: "third",
-			Site:    "party",
-			Version: "v2",
-		}, nil
+	parts, err = r.parseGrammars(name)
+	if err != ErrNoMatch {
+		return parts, err
 	}
 
 	fields := strings.Split(name, ".")
@@ -53,12 +56,12 @@ func Parse(name string) (Name, error) {
 			return parts, err
 		}
 	case len(fields) == 4 && len(fields[0]) > 6:
-		parts, err = parseHostV2(fields)
+		parts, err = r.parseHostV2(fields)
 		if err != nil {
 			return parts, err
 		}
 	default:
-		parts, err = parseHostV1(name)
+		parts, err = r.parseHostV1(name)
 		if err != nil {
 			return parts, err
 		}
@@ -67,17 +70,23 @@ func Parse(name string) (Name, error) {
 	return parts, nil
 }
 
+// reV1 matches v1 hostnames in any domain. The domain is checked against the
+// registry separately.
+var reV1 = regexp.MustCompile(`(?:[a-z-.]+)?(mlab[1-4]d?)[-.]([a-z]{3}[0-9tc]{2})\.([a-z0-9-]+(?:\.[a-z0-9-]+)+)$`)
+
 // v1 - Example hostnames with field counts when split by '.':
 //
 //	mlab1.lga01.measurement-lab.org - 4
 //	ndt-iupui-mlab1-lga01.measurement-lab.org  - 3
 //	ndt.iupui.mlab1.lga01.measurement-lab.org  - 6
-func parseHostV1(h string) (Name, error) {
-	reV1 := regexp.MustCompile(`(?:[a-z-.]+)?(mlab[1-4]d?)[-.]([a-z]{3}[0-9tc]{2})\.(measurement-lab.org)$`)
+func (r *Registry) parseHostV1(h string) (Name, error) {
 	mV1 := reV1.FindAllStringSubmatch(h, -1)
 	if len(mV1) != 1 || len(mV1[0]) != 4 {
 		return Name{}, fmt.Errorf("invalid v1 hostname: %s", h)
 	}
+	if _, ok := r.org(mV1[0][3]); !ok {
+		return Name{}, fmt.Errorf("invalid domain: %s", h)
+	}
 	parts := Name{
 		Machine: mV1[0][1],
 		Site:    mV1[0][2],
@@ -95,7 +104,7 @@ func parseHostV1(h string) (Name, error) {
 //	ndt-mlab1-lga01.mlab-oti.measurement-lab.org-d9h6 - 4 (A MIG instance with a service and random suffix)
 //	ndt-iupui-mlab1-lga01.mlab-oti.measurement-lab.org - 4
 //	ndt-mlab1-lga01.mlab-oti.measurement-lab.org - 4
-func parseHostV2(f []string) (Name, error) {
+func (r *Registry) parseHostV2(f []string) (Name, error) {
 	if len(f) != 4 || len(f[0]) < 7 {
 		return Name{}, fmt.Errorf("invalid v2 hostname: %#v", f)
 	}
@@ -135,14 +144,15 @@ func parseHostV2(f []string) (Name, error) {
 	if len(sd) == 2 {
 		suffix = "-" + sd[1]
 	}
-	if domain != "measurement-lab.org" {
+	org, ok := r.org(domain)
+	if !ok {
 		return Name{}, fmt.Errorf("invalid domain: %#v", f)
 	}
-	// All V2 names represent machines that are managed by M-Lab, and join the
-	// M-Lab platform cluster. Unconditionally set Org to "mlab" rather than
-	// leaving it blank.
+	// All V2 names represent machines that are managed by the organization of
+	// their domain (M-Lab for measurement-lab.org), and join its platform
+	// cluster. Unconditionally set Org rather than leaving it blank.
 	parts := Name{
-		Org:     "mlab",
+		Org:     org,
 		Service: service,
 		Machine: machine,
 		Site:    site,
@@ -231,15 +241,51 @@ func (n Name) String() string {
 	}
 	switch n.Version {
 	case "v3":
-		// NOTE: v3 names include the service and are equivalent to the machine name.
-		return fmt.Sprintf("%s-%s-%s.%s.%s.%s", n.Service, n.Site, n.Machine, n.Org, n.Project, n.Domain)
+		return n.formatV3()
 	case "v2":
-		return fmt.Sprintf("%s-%s.%s.%s", n.Machine, n.Site, n.Project, n.Domain)
+		return n.formatV2()
 	default:
-		return fmt.Sprintf("%s.%s.%s", n.Machine, n.Site, n.Domain)
+		return n.formatV1()
 	}
 }
 
+func (n Name) formatV1() string {
+	return fmt.Sprintf("%s.%s.%s", n.Machine, n.Site, n.Domain)
+}
+
+func (n Name) formatV2() string {
+	return fmt.Sprintf("%s-%s.%s.%s", n.Machine, n.Site, n.Project, n.Domain)
+}
+
+func (n Name) formatV3() string {
+	// NOTE: v3 names include the service and are equivalent to the machine name.
+	if n.Service == "" {
+		return fmt.Sprintf("%s-%s.%s.%s.%s", n.Site, n.Machine, n.Org, n.Project, n.Domain)
+	}
+	return fmt.Sprintf("%s-%s-%s.%s.%s.%s", n.Service, n.Site, n.Machine, n.Org, n.Project, n.Domain)
+}
+
+// V1 returns the v1 hostname of the machine, regardless of n.Version, as
+// DefaultRegistry.V1 does.
+// Example: mlab1.abc01.measurement-lab.org
+func (n Name) V1() (string, error) {
+	return DefaultRegistry.V1(n)
+}
+
+// V2 returns the v2 hostname of the machine, regardless of n.Version, as
+// DefaultRegistry.V2 does. Any service and suffix are not included.
+// Example: mlab1-abc01.mlab-sandbox.measurement-lab.org
+func (n Name) V2() (string, error) {
+	return DefaultRegistry.V2(n)
+}
+
+// V3 returns the v3 hostname of the machine, including any service,
+// regardless of n.Version, as DefaultRegistry.V3 does.
+// Example: ndt-abc3356-c0a80001.mlab.sandbox.measurement-lab.org
+func (n Name) V3() (string, error) {
+	return DefaultRegistry.V3(n)
+}
+
 // Returns an M-lab hostname with any service name preserved
 // Example: ndt-mlab1-abc01.mlab-sandbox.measurement-lab.org
 func (n Name) StringWithService() string {
@@ -1,6 +1,7 @@
 package host
 
 import (
+	"math/rand"
 	"reflect"
 	"testing"
 
@@ -302,6 +303,10 @@ func TestName_String(t *testing.T) {
 			name: "ndt-lol12345-abcdef01.mlab.sandbox.measurement-lab.org",
 			want: "ndt-lol12345-abcdef01.mlab.sandbox.measurement-lab.org",
 		},
+		{
+			name: "lol12345-abcdef01.mlab.sandbox.measurement-lab.org",
+			want: "lol12345-abcdef01.mlab.sandbox.measurement-lab.org",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -429,6 +434,176 @@ func TestName_StringAll(t *testing.T) {
 	}
 }
 
+func TestName_Versions(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		org     string
+		wantV1  string
+		wantV2  string
+		wantV3  string
+	}{
+		{
+			name:    "mlab1.foo01.measurement-lab.org",
+			project: "mlab-oti",
+			wantV1:  "mlab1.foo01.measurement-lab.org",
+			wantV2:  "mlab1-foo01.mlab-oti.measurement-lab.org",
+		},
+		{
+			name:   "mlab1.foo0c.measurement-lab.org",
+			wantV1: "mlab1.foo0c.measurement-lab.org",
+		},
+		{
+			name:   "mlab1-foo01..measurement-lab.org",
+			wantV1: "mlab1.foo01.measurement-lab.org",
+		},
+		{
+			name: "lol12345-abcdef01..sandbox.measurement-lab.org",
+		},
+		{
+			name:   "ndt-mlab1d-foo01.mlab-sandbox.measurement-lab.org-qf8y",
+			wantV1: "mlab1d.foo01.measurement-lab.org",
+			wantV2: "mlab1d-foo01.mlab-sandbox.measurement-lab.org",
+		},
+		{
+			name:   "ndt-lol12345-abcdef01.mlab.sandbox.measurement-lab.org",
+			wantV3: "ndt-lol12345-abcdef01.mlab.sandbox.measurement-lab.org",
+		},
+		{
+			name:   "lol12345-abcdef01.rnp.autojoin.measurement-lab.org",
+			wantV3: "lol12345-abcdef01.rnp.autojoin.measurement-lab.org",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse(tt.name)
+			testingx.Must(t, err, "Failed to parse: %s", tt.name)
+			if tt.project != "" {
+				n.Project = tt.project
+			}
+			for _, f := range []struct {
+				version string
+				format  func() (string, error)
+				want    string
+			}{
+				{"v1", n.V1, tt.wantV1},
+				{"v2", n.V2, tt.wantV2},
+				{"v3", n.V3, tt.wantV3},
+			} {
+				got, err := f.format()
+				if (err != nil) != (f.want == "") {
+					t.Errorf("Name.%s() error = %v, want %q", f.version, err, f.want)
+				}
+				if got != f.want {
+					t.Errorf("Name.%s() = %q, want %q", f.version, got, f.want)
+				}
+			}
+		})
+	}
+}
+
+// randomName returns a random valid Name of the given version. Service and
+// suffix are included at random when the version supports them.
+func randomName(r *rand.Rand, version string) Name {
+	letters := func(min, max int, alphabet string) string {
+		b := make([]byte, min+r.Intn(max-min+1))
+		for i := range b {
+			b[i] = alphabet[r.Intn(len(alphabet))]
+		}
+		return string(b)
+	}
+	const (
+		lower  = "abcdefghijklmnopqrstuvwxyz"
+		digits = "0123456789"
+		hex    = "0123456789abcdef"
+	)
+	machine := "mlab" + letters(1, 1, "1234") + letters(0, 1, "d")
+	site := letters(3, 3, lower) + letters(1, 1, digits) + letters(1, 1, digits+"t")
+	switch version {
+	case "v1":
+		return Name{
+			Machine: machine,
+			Site:    site,
+			Domain:  "measurement-lab.org",
+			Version: "v1",
+		}
+	case "v2":
+		n := Name{
+			Org:     "mlab",
+			Service: letters(0, 5, lower),
+			Machine: machine,
+			Site:    site,
+			Project: "mlab-" + letters(1, 10, lower),
+			Domain:  "measurement-lab.org",
+			Version: "v2",
+		}
+		if r.Intn(2) == 0 {
+			n.Suffix = "-" + letters(4, 4, lower+digits)
+		}
+		return n
+	default:
+		return Name{
+			Service: letters(0, 5, lower),
+			Site:    letters(3, 3, lower) + letters(1, 10, digits),
+			Machine: letters(8, 8, hex),
+			Org:     letters(1, 10, lower),
+			Project: letters(1, 10, lower+"-"),
+			Domain:  "measurement-lab.org",
+			Version: "v3",
+		}
+	}
+}
+
+func TestName_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, version := range []string{"v1", "v2", "v3"} {
+		format := map[string]func(Name) (string, error){
+			"v1": Name.V1,
+			"v2": Name.V2,
+			"v3": Name.V3,
+		}[version]
+		for i := 0; i < 1000; i++ {
+			n := randomName(r, version)
+			// StringAll preserves every field of every version.
+			got, err := Parse(n.StringAll())
+			if err != nil || got != n {
+				t.Fatalf("Parse(%q) = %+v, %v; want %+v", n.StringAll(), got, err, n)
+			}
+			// String and the formatter of the version drop the suffix and,
+			// before v3, the service.
+			want := n
+			want.Suffix = ""
+			if version != "v3" {
+				want.Service = ""
+			}
+			if got, err := Parse(n.String()); err != nil || got != want {
+				t.Fatalf("Parse(%q) = %+v, %v; want %+v", n.String(), got, err, want)
+			}
+			s, err := format(n)
+			if err != nil || s != n.String() {
+				t.Fatalf("Name.%s() = %q, %v; want %q", version, s, err, n.String())
+			}
+		}
+	}
+}
+
+func TestName_ConvertV1V2(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < 1000; i++ {
+		n := randomName(r, "v2")
+		v1, err := n.V1()
+		testingx.Must(t, err, "Failed to format %+v as v1", n)
+		parsed, err := Parse(v1)
+		testingx.Must(t, err, "Failed to parse: %s", v1)
+		// Converting back to v2 only needs the project, which v1 names lack.
+		parsed.Project = n.Project
+		v2, err := parsed.V2()
+		if err != nil || v2 != n.String() {
+			t.Fatalf("%q converted to %q and back to %q, %v", n.String(), v1, v2, err)
+		}
+	}
+}
+
 func BenchmarkParse(b *testing.B) {
 	// run Parse on all service names b.N times.
 	names := []string{
@@ -0,0 +1,167 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrNoMatch is returned by a Grammar for hostnames it does not recognize.
+var ErrNoMatch = errors.New("hostname does not match grammar")
+
+// Grammar parses the hostnames of a naming scheme that is not built into this
+// package. It must return ErrNoMatch for hostnames it does not recognize, so
+// that other grammars can try to parse them. Any other error is returned by
+// Parse as is.
+type Grammar func(hostname string) (Name, error)
+
+// Registry holds the domains and grammars that Parse accepts. Private
+// deployments can register their own domains and naming schemes in the
+// DefaultRegistry, which is used by Parse, or use a Registry of their own.
+type Registry struct {
+	mu sync.RWMutex
+	// domains maps every domain accepted in v1 and v2 names to the Org of v2
+	// names in that domain.
+	domains  map[string]string
+	grammars []Grammar
+}
+
+// DefaultRegistry is the Registry used by Parse and the formatters of Name.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns a Registry that accepts the names of the M-Lab
+// platform: v1 and v2 names in the measurement-lab.org domain, v3 names, and
+// "third-party".
+func NewRegistry() *Registry {
+	r := &Registry{domains: map[string]string{}}
+	r.RegisterDomain("measurement-lab.org", "mlab")
+	r.RegisterGrammar(parseThirdParty)
+	return r
+}
+
+// RegisterDomain adds a domain that is accepted in v1 and v2 names. All v2
+// names in the domain get the given Org. Because v2 names have exactly four
+// dot-separated fields, only domains with two labels (e.g. "example.com")
+// can be used in v2 names. v3 names already accept any domain, and set the
+// Org from the name.
+func (r *Registry) RegisterDomain(domain, org string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.domains[domain] = org
+}
+
+// RegisterGrammar adds a grammar. Grammars are tried in the order they were
+// registered, before the built-in v1, v2 and v3 grammars.
+func (r *Registry) RegisterGrammar(g Grammar) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.grammars = append(r.grammars, g)
+}
+
+// org returns the Org of v2 names in the domain, and whether the domain is
+// registered.
+func (r *Registry) org(domain string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	org, ok := r.domains[domain]
+	return org, ok
+}
+
+// parseGrammars tries every registered grammar, and returns ErrNoMatch if none
+// recognizes the name.
+func (r *Registry) parseGrammars(name string) (Name, error) {
+	r.mu.RLock()
+	grammars := r.grammars
+	r.mu.RUnlock()
+	for _, g := range grammars {
+		n, err := g(name)
+		if err != ErrNoMatch {
+			return n, err
+		}
+	}
+	return Name{}, ErrNoMatch
+}
+
+// V1 returns the v1 hostname of the machine, regardless of n.Version.
+//
+// V1 returns an error if the machine and site cannot be written as a v1
+// hostname that r parses, e.g. because they come from a v3 name.
+func (r *Registry) V1(n Name) (string, error) {
+	return r.format(n, "v1", n.formatV1(), Name{
+		Machine: n.Machine,
+		Site:    n.Site,
+		Domain:  n.Domain,
+		Version: "v1",
+	})
+}
+
+// V2 returns the v2 hostname of the machine, regardless of n.Version. Any
+// service and suffix are not included.
+//
+// V2 returns an error if the name cannot be written as a v2 hostname that r
+// parses, e.g. because it has no Project, as is the case for names parsed
+// from v1 hostnames.
+func (r *Registry) V2(n Name) (string, error) {
+	org, _ := r.org(n.Domain)
+	return r.format(n, "v2", n.formatV2(), Name{
+		Org:     org,
+		Machine: n.Machine,
+		Site:    n.Site,
+		Project: n.Project,
+		Domain:  n.Domain,
+		Version: "v2",
+	})
+}
+
+// V3 returns the v3 hostname of the machine, including any service,
+// regardless of n.Version.
+//
+// V3 returns an error if the name cannot be written as a v3 hostname that r
+// parses, e.g. because it has no Org, or its site has no ASN.
+func (r *Registry) V3(n Name) (string, error) {
+	return r.format(n, "v3", n.formatV3(), Name{
+		Service: n.Service,
+		Site:    n.Site,
+		Machine: n.Machine,
+		Org:     n.Org,
+		Project: n.Project,
+		Domain:  n.Domain,
+		Version: "v3",
+	})
+}
+
+// format verifies that hostname parses back into want, so that formatters
+// never return a hostname that means something other than n.
+func (r *Registry) format(n Name, version, hostname string, want Name) (string, error) {
+	// Parse accepts empty projects and orgs, but formatters never produce them.
+	if (version != "v1" && want.Project == "") || (version == "v3" && want.Org == "") {
+		return "", fmt.Errorf("cannot format %+v as a %s hostname", n, version)
+	}
+	got, err := r.Parse(hostname)
+	if err != nil || got != want {
+		return "", fmt.Errorf("cannot format %+v as a %s hostname", n, version)
+	}
+	return hostname, nil
+}
+
+// RegisterDomain adds a domain to the DefaultRegistry.
+func RegisterDomain(domain, org string) {
+	DefaultRegistry.RegisterDomain(domain, org)
+}
+
+// RegisterGrammar adds a grammar to the DefaultRegistry.
+func RegisterGrammar(g Grammar) {
+	DefaultRegistry.RegisterGrammar(g)
+}
+
+func parseThirdParty(name string) (Name, error) {
+	if name != "third-party" {
+		return Name{}, ErrNoMatch
+	}
+	// Unconditionally return a Name for third-party origins.
+	return Name{
+		Machine: "third",
+		Site:    "party",
+		Version: "v2",
+	}, nil
+}
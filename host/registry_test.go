@@ -0,0 +1,147 @@
+package host
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterDomain("example.com", "acme")
+	r.RegisterGrammar(func(hostname string) (Name, error) {
+		// A flat naming scheme like "node-7.lab".
+		if !strings.HasSuffix(hostname, ".lab") {
+			return Name{}, ErrNoMatch
+		}
+		machine := strings.TrimSuffix(hostname, ".lab")
+		if !strings.HasPrefix(machine, "node-") {
+			return Name{}, errors.New("lab machines must be named node-N")
+		}
+		return Name{Machine: machine, Site: "lab", Org: "acme", Version: "lab"}, nil
+	})
+	tests := []struct {
+		name     string
+		hostname string
+		want     Name
+		wantErr  bool
+	}{
+		{
+			name:     "builtin-v2",
+			hostname: "mlab1-lol01.mlab-sandbox.measurement-lab.org",
+			want: Name{
+				Org:     "mlab",
+				Machine: "mlab1",
+				Site:    "lol01",
+				Project: "mlab-sandbox",
+				Domain:  "measurement-lab.org",
+				Version: "v2",
+			},
+		},
+		{
+			name:     "builtin-third-party",
+			hostname: "third-party",
+			want:     Name{Machine: "third", Site: "party", Version: "v2"},
+		},
+		{
+			name:     "registered-domain-v1",
+			hostname: "ndt.iupui.mlab1.lol01.example.com",
+			want: Name{
+				Machine: "mlab1",
+				Site:    "lol01",
+				Domain:  "example.com",
+				Version: "v1",
+			},
+		},
+		{
+			name:     "registered-domain-v2",
+			hostname: "ndt-mlab1-lol01.acme-prod.example.com-a9b8",
+			want: Name{
+				Org:     "acme",
+				Service: "ndt",
+				Machine: "mlab1",
+				Site:    "lol01",
+				Project: "acme-prod",
+				Domain:  "example.com",
+				Suffix:  "-a9b8",
+				Version: "v2",
+			},
+		},
+		{
+			name:     "registered-grammar",
+			hostname: "node-7.lab",
+			want:     Name{Machine: "node-7", Site: "lab", Org: "acme", Version: "lab"},
+		},
+		{
+			name:     "registered-grammar-error",
+			hostname: "gpu-1.lab",
+			wantErr:  true,
+		},
+		{
+			name:     "unregistered-domain-v1",
+			hostname: "mlab1.lol01.example.net",
+			wantErr:  true,
+		},
+		{
+			name:     "unregistered-domain-v2",
+			hostname: "mlab1-lol01.acme-prod.example.net",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Parse(tt.hostname)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Registry.Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Registry.Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Registering in one registry does not affect others.
+	if _, err := Parse("mlab1.lol01.example.com"); err == nil {
+		t.Error("Parse() should not accept domains registered in another registry")
+	}
+
+	// Names in the registry's domains can be formatted by it, but not by the
+	// DefaultRegistry.
+	n, err := r.Parse("mlab1-lol01.partner-oti.example.com")
+	if err != nil {
+		t.Fatalf("Registry.Parse() = %v", err)
+	}
+	if v1, err := r.V1(n); err != nil || v1 != "mlab1.lol01.example.com" {
+		t.Errorf("Registry.V1() = %q, %v", v1, err)
+	}
+	if v2, err := r.V2(n); err != nil || v2 != "mlab1-lol01.partner-oti.example.com" {
+		t.Errorf("Registry.V2() = %q, %v", v2, err)
+	}
+	if v2, err := n.V2(); err == nil {
+		t.Errorf("Name.V2() = %q, want an error for a domain not in DefaultRegistry", v2)
+	}
+}
+
+func TestRegisterDefault(t *testing.T) {
+	old := DefaultRegistry
+	DefaultRegistry = NewRegistry()
+	t.Cleanup(func() { DefaultRegistry = old })
+
+	RegisterDomain("example.org", "partner")
+	RegisterGrammar(func(hostname string) (Name, error) {
+		if hostname != "localhost" {
+			return Name{}, ErrNoMatch
+		}
+		return Name{Machine: "localhost", Version: "local"}, nil
+	})
+	n, err := Parse("mlab2-lol01.partner-oti.example.org")
+	if err != nil || n.Org != "partner" {
+		t.Fatalf("Parse() = %+v, %v; want a name with Org partner", n, err)
+	}
+	if v2, err := n.V2(); err != nil || v2 != "mlab2-lol01.partner-oti.example.org" {
+		t.Errorf("Name.V2() = %q, %v", v2, err)
+	}
+	if n, err := Parse("localhost"); err != nil || n.Version != "local" {
+		t.Errorf("Parse() = %+v, %v; want a local name", n, err)
+	}
+}
@@ -0,0 +1,227 @@
+package host
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// The patterns below are deliberately stricter than Parse, which keeps
+// accepting the names it always has (e.g. uppercase sites, or v3 machines that
+// are not hex) so that existing callers are not broken. Use the types below to
+// check names that are being created or configured.
+var (
+	// Sites are three letters followed by either two characters (v1 and v2
+	// names, e.g. "lga01" or "lga0t") or an ASN of up to ten digits (v3 names,
+	// e.g. "lga3356").
+	siteRE = regexp.MustCompile(`^[a-z]{3}([0-9]{1,10}|[0-9][tc])$`)
+	// Machines are either "mlab1" through "mlab4" with an optional "d" for
+	// DRACs (v1 and v2 names), or eight hex digits (v3 names).
+	machineRE = regexp.MustCompile(`^(mlab[1-4]d?|[0-9a-f]{8})$`)
+	// Services are a lowercase word, e.g. "ndt" or "wehe".
+	serviceRE = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+	// Projects follow the rules for GCP project IDs, e.g. "mlab-oti".
+	projectRE = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+)
+
+func validate(kind string, re *regexp.Regexp, s string) error {
+	if !re.MatchString(s) {
+		return fmt.Errorf("invalid %s: %q", kind, s)
+	}
+	return nil
+}
+
+// Site is the name of an M-Lab site, e.g. "lga01" or "lga3356". The zero
+// value is not a valid Site. Site implements flag.Value and
+// encoding.TextUnmarshaler, so invalid sites are rejected when flags or
+// config files are parsed.
+type Site string
+
+// ParseSite returns the Site named by s, or an error if s is not valid.
+func ParseSite(s string) (Site, error) {
+	if err := Site(s).Validate(); err != nil {
+		return "", err
+	}
+	return Site(s), nil
+}
+
+// Validate returns an error if the site is not valid.
+func (s Site) Validate() error {
+	return validate("site", siteRE, string(s))
+}
+
+// String returns the site name.
+func (s Site) String() string {
+	return string(s)
+}
+
+// Get is required for all flag.Getter values.
+func (s Site) Get() interface{} {
+	return s
+}
+
+// Set is required for all flag.Value values.
+func (s *Site) Set(v string) error {
+	return s.UnmarshalText([]byte(v))
+}
+
+// MarshalText returns the site name.
+func (s Site) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText sets the site to text, if it is valid.
+func (s *Site) UnmarshalText(text []byte) error {
+	v, err := ParseSite(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// Machine is the name of an M-Lab machine within its site, e.g. "mlab1" or
+// "c0a80001". The zero value is not a valid Machine. Machine implements
+// flag.Value and encoding.TextUnmarshaler, so invalid machines are rejected
+// when flags or config files are parsed.
+type Machine string
+
+// ParseMachine returns the Machine named by s, or an error if s is not valid.
+func ParseMachine(s string) (Machine, error) {
+	if err := Machine(s).Validate(); err != nil {
+		return "", err
+	}
+	return Machine(s), nil
+}
+
+// Validate returns an error if the machine is not valid.
+func (m Machine) Validate() error {
+	return validate("machine", machineRE, string(m))
+}
+
+// String returns the machine name.
+func (m Machine) String() string {
+	return string(m)
+}
+
+// Get is required for all flag.Getter values.
+func (m Machine) Get() interface{} {
+	return m
+}
+
+// Set is required for all flag.Value values.
+func (m *Machine) Set(v string) error {
+	return m.UnmarshalText([]byte(v))
+}
+
+// MarshalText returns the machine name.
+func (m Machine) MarshalText() ([]byte, error) {
+	return []byte(m), nil
+}
+
+// UnmarshalText sets the machine to text, if it is valid.
+func (m *Machine) UnmarshalText(text []byte) error {
+	v, err := ParseMachine(string(text))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// Service is the name of a service running on M-Lab machines, e.g. "ndt".
+// The zero value is not a valid Service. Service implements flag.Value and
+// encoding.TextUnmarshaler, so invalid services are rejected when flags or
+// config files are parsed.
+type Service string
+
+// ParseService returns the Service named by s, or an error if s is not valid.
+func ParseService(s string) (Service, error) {
+	if err := Service(s).Validate(); err != nil {
+		return "", err
+	}
+	return Service(s), nil
+}
+
+// Validate returns an error if the service is not valid.
+func (s Service) Validate() error {
+	return validate("service", serviceRE, string(s))
+}
+
+// String returns the service name.
+func (s Service) String() string {
+	return string(s)
+}
+
+// Get is required for all flag.Getter values.
+func (s Service) Get() interface{} {
+	return s
+}
+
+// Set is required for all flag.Value values.
+func (s *Service) Set(v string) error {
+	return s.UnmarshalText([]byte(v))
+}
+
+// MarshalText returns the service name.
+func (s Service) MarshalText() ([]byte, error) {
+	return []byte(s), nil
+}
+
+// UnmarshalText sets the service to text, if it is valid.
+func (s *Service) UnmarshalText(text []byte) error {
+	v, err := ParseService(string(text))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// Project is the name of the GCP project that manages a machine, e.g.
+// "mlab-oti". The zero value is not a valid Project. Project implements
+// flag.Value and encoding.TextUnmarshaler, so invalid projects are rejected
+// when flags or config files are parsed.
+type Project string
+
+// ParseProject returns the Project named by s, or an error if s is not valid.
+func ParseProject(s string) (Project, error) {
+	if err := Project(s).Validate(); err != nil {
+		return "", err
+	}
+	return Project(s), nil
+}
+
+// Validate returns an error if the project is not valid.
+func (p Project) Validate() error {
+	return validate("project", projectRE, string(p))
+}
+
+// String returns the project name.
+func (p Project) String() string {
+	return string(p)
+}
+
+// Get is required for all flag.Getter values.
+func (p Project) Get() interface{} {
+	return p
+}
+
+// Set is required for all flag.Value values.
+func (p *Project) Set(v string) error {
+	return p.UnmarshalText([]byte(v))
+}
+
+// MarshalText returns the project name.
+func (p Project) MarshalText() ([]byte, error) {
+	return []byte(p), nil
+}
+
+// UnmarshalText sets the project to text, if it is valid.
+func (p *Project) UnmarshalText(text []byte) error {
+	v, err := ParseProject(string(text))
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
@@ -0,0 +1,157 @@
+package host
+
+import (
+	"encoding/json"
+	"flag"
+	"testing"
+
+	"github.com/m-lab/go/testingx"
+)
+
+func TestTypedValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       interface{ Validate() error }
+		wantErr bool
+	}{
+		{name: "site-v2", v: Site("lga01")},
+		{name: "site-v2-testing", v: Site("lga0t")},
+		{name: "site-v1-cloud", v: Site("lga0c")},
+		{name: "site-v3", v: Site("lga3356")},
+		{name: "site-v3-long-asn", v: Site("lga4294967295")},
+		{name: "site-empty", v: Site(""), wantErr: true},
+		{name: "site-uppercase", v: Site("LGA01"), wantErr: true},
+		{name: "site-too-short", v: Site("lg01"), wantErr: true},
+		{name: "site-asn-too-long", v: Site("lga12345678901"), wantErr: true},
+		{name: "site-bad-suffix", v: Site("lga0x"), wantErr: true},
+		{name: "machine-v2", v: Machine("mlab1")},
+		{name: "machine-v2-drac", v: Machine("mlab4d")},
+		{name: "machine-v3", v: Machine("c0a80001")},
+		{name: "machine-empty", v: Machine(""), wantErr: true},
+		{name: "machine-mlab5", v: Machine("mlab5"), wantErr: true},
+		{name: "machine-v3-not-hex", v: Machine("c0a8000g"), wantErr: true},
+		{name: "machine-v3-too-long", v: Machine("c0a800011"), wantErr: true},
+		{name: "service", v: Service("ndt")},
+		{name: "service-with-digits", v: Service("ndt7")},
+		{name: "service-empty", v: Service(""), wantErr: true},
+		{name: "service-dash", v: Service("ndt-iupui"), wantErr: true},
+		{name: "service-leading-digit", v: Service("7ndt"), wantErr: true},
+		{name: "project", v: Project("mlab-oti")},
+		{name: "project-v3", v: Project("autojoin")},
+		{name: "project-empty", v: Project(""), wantErr: true},
+		{name: "project-too-short", v: Project("mlab"), wantErr: true},
+		{name: "project-trailing-dash", v: Project("mlab-oti-"), wantErr: true},
+		{name: "project-dot", v: Project("mlab.oti"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.v.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTypedValidate_StricterThanParse(t *testing.T) {
+	// Parse accepts these names for compatibility, but their parts are not
+	// valid typed values.
+	for _, hostname := range []string{
+		"mlab1.lgat1.measurement-lab.org",
+		"ndt-LGA3356-c0a80001.mlab.autojoin.measurement-lab.org",
+		"ndt-lga3356-C0A80001.mlab.autojoin.measurement-lab.org",
+	} {
+		n, err := Parse(hostname)
+		if err != nil {
+			t.Errorf("Parse(%q) = %v", hostname, err)
+			continue
+		}
+		if Site(n.Site).Validate() == nil && Machine(n.Machine).Validate() == nil {
+			t.Errorf("Parse(%q) = %+v, want an invalid Site or Machine", hostname, n)
+		}
+	}
+}
+
+func TestTypedParse(t *testing.T) {
+	if s, err := ParseSite("lga01"); err != nil || s != "lga01" || s.String() != "lga01" {
+		t.Errorf("ParseSite() = %q, %v", s, err)
+	}
+	if s, err := ParseSite("bad"); err == nil || s != "" {
+		t.Errorf("ParseSite() = %q, %v; want an error", s, err)
+	}
+	if m, err := ParseMachine("mlab1"); err != nil || m != "mlab1" || m.String() != "mlab1" {
+		t.Errorf("ParseMachine() = %q, %v", m, err)
+	}
+	if m, err := ParseMachine("bad"); err == nil || m != "" {
+		t.Errorf("ParseMachine() = %q, %v; want an error", m, err)
+	}
+	if s, err := ParseService("ndt"); err != nil || s != "ndt" || s.String() != "ndt" {
+		t.Errorf("ParseService() = %q, %v", s, err)
+	}
+	if s, err := ParseService("Bad"); err == nil || s != "" {
+		t.Errorf("ParseService() = %q, %v; want an error", s, err)
+	}
+	if p, err := ParseProject("mlab-oti"); err != nil || p != "mlab-oti" || p.String() != "mlab-oti" {
+		t.Errorf("ParseProject() = %q, %v", p, err)
+	}
+	if p, err := ParseProject("bad"); err == nil || p != "" {
+		t.Errorf("ParseProject() = %q, %v; want an error", p, err)
+	}
+}
+
+func TestTypedFlags(t *testing.T) {
+	var (
+		site    Site
+		machine Machine
+		service Service
+		project Project
+	)
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&site, "site", "")
+	fs.Var(&machine, "machine", "")
+	fs.Var(&service, "service", "")
+	fs.Var(&project, "project", "")
+	err := fs.Parse([]string{"-site=lga01", "-machine=mlab2", "-service=ndt", "-project=mlab-oti"})
+	testingx.Must(t, err, "Could not parse flags")
+	if site.Get() != Site("lga01") || machine.Get() != Machine("mlab2") || service.Get() != Service("ndt") || project.Get() != Project("mlab-oti") {
+		t.Errorf("flags = %q %q %q %q", site, machine, service, project)
+	}
+	for _, arg := range []string{"-site=bad", "-machine=bad", "-service=Bad", "-project=bad"} {
+		if fs.Parse([]string{arg}) == nil {
+			t.Errorf("Parse(%q) should have failed", arg)
+		}
+	}
+	// Failed parses leave the values unchanged.
+	if site != "lga01" || machine != "mlab2" || service != "ndt" || project != "mlab-oti" {
+		t.Errorf("flags = %q %q %q %q", site, machine, service, project)
+	}
+}
+
+func TestTypedJSON(t *testing.T) {
+	type config struct {
+		Site    Site
+		Machine Machine
+		Service Service
+		Project Project
+	}
+	want := config{Site: "lga3356", Machine: "c0a80001", Service: "ndt", Project: "autojoin"}
+	b, err := json.Marshal(want)
+	testingx.Must(t, err, "Could not marshal")
+	if string(b) != `{"Site":"lga3356","Machine":"c0a80001","Service":"ndt","Project":"autojoin"}` {
+		t.Errorf("json.Marshal() = %s", b)
+	}
+	var got config
+	testingx.Must(t, json.Unmarshal(b, &got), "Could not unmarshal %s", b)
+	if got != want {
+		t.Errorf("json.Unmarshal() = %+v, want %+v", got, want)
+	}
+	for _, bad := range []string{
+		`{"Site":"lga"}`,
+		`{"Machine":"mlab9"}`,
+		`{"Service":"ndt-iupui"}`,
+		`{"Project":"x"}`,
+	} {
+		if json.Unmarshal([]byte(bad), &got) == nil {
+			t.Errorf("json.Unmarshal(%s) should have failed", bad)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package memoryless
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Rand is the source of randomness used to generate wait times. *rand.Rand
+// implements it, so a deterministic sequence of wait times can be generated
+// with:
+//
+//	Config{Expected: time.Second, Rand: rand.New(rand.NewSource(seed))}
+type Rand interface {
+	ExpFloat64() float64
+	Float64() float64
+	NormFloat64() float64
+}
+
+// globalRand is a Rand that uses the top-level functions of math/rand.
+type globalRand struct{}
+
+func (globalRand) ExpFloat64() float64  { return rand.ExpFloat64() }
+func (globalRand) Float64() float64     { return rand.Float64() }
+func (globalRand) NormFloat64() float64 { return rand.NormFloat64() }
+
+// Timer is the interface of single-shot timers created by a Clock. It mirrors
+// the parts of time.Timer that this package uses.
+type Timer interface {
+	// C returns the channel on which the time is delivered when the timer
+	// fires. Timers created by AfterFunc never deliver on this channel.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It returns false if the timer has
+	// already fired or been stopped.
+	Stop() bool
+}
+
+// Clock is the source of time and timers. The default is the system clock.
+// Tests may substitute a fake clock, such as memorylesstest.FakeClock, to
+// control time precisely.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// realClock is a Clock that uses the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+// realTimer adapts a *time.Timer to the Timer interface.
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time {
+	return r.t.C
+}
+
+func (r realTimer) Stop() bool {
+	return r.t.Stop()
+}
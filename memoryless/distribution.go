@@ -0,0 +1,112 @@
+package memoryless
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Distribution is an enum, suitable for use as a command-line flag, that
+// selects the distribution of the wait times between events.
+//
+// Only the exponential distribution produces a memoryless (Poisson) process.
+// The others are provided for scheduling experiments, and a Ticker using them
+// does not have the PASTA property.
+type Distribution string
+
+// The supported distributions. Every distribution is parameterized so that its
+// mean is Config.Expected, before any clamping by Config.Min and Config.Max.
+var (
+	// Exponential wait times produce a memoryless process. It is the default.
+	Exponential = Distribution("exponential")
+	// Uniform wait times are distributed uniformly between zero and twice
+	// Config.Expected.
+	Uniform = Distribution("uniform")
+	// Pareto wait times follow a heavy-tailed Pareto distribution. Config.Shape
+	// is the shape parameter (often called alpha) and must be greater than 1
+	// for the mean to exist. Smaller shapes give heavier tails.
+	Pareto = Distribution("pareto")
+	// LogNormal wait times follow a log-normal distribution. Config.Shape is
+	// the standard deviation of the underlying normal distribution (often
+	// called sigma) and must be positive.
+	LogNormal = Distribution("lognormal")
+	// ConstantJitter wait times are Config.Expected plus a jitter distributed
+	// uniformly between -Config.Jitter and +Config.Jitter.
+	ConstantJitter = Distribution("constant-jitter")
+)
+
+var distributions = []Distribution{Exponential, Uniform, Pareto, LogNormal, ConstantJitter}
+
+// Get is required for all flag.Flag values.
+func (d Distribution) Get() interface{} {
+	return d
+}
+
+// Set is required for all flag.Flag values.
+func (d *Distribution) Set(s string) error {
+	for _, known := range distributions {
+		if Distribution(s) == known {
+			*d = known
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown distribution %q, valid values are %q", s, distributions)
+}
+
+// String is required for all flag.Flag values.
+func (d Distribution) String() string {
+	return string(d)
+}
+
+// checkDistribution returns an error if the distribution-specific parameters of the config
+// are invalid.
+func (c Config) checkDistribution() error {
+	switch c.Distribution {
+	case "", Exponential, Uniform:
+	case Pareto:
+		if !(c.Shape > 1) {
+			return fmt.Errorf("the pareto distribution requires Shape > 1, not %v", c.Shape)
+		}
+	case LogNormal:
+		if !(c.Shape > 0) {
+			return fmt.Errorf("the lognormal distribution requires Shape > 0, not %v", c.Shape)
+		}
+	case ConstantJitter:
+		if c.Jitter < 0 || c.Jitter > c.Expected {
+			return fmt.Errorf("the constant-jitter distribution requires 0 <= Jitter <= Expected, not Jitter(%v) Expected(%v)", c.Jitter, c.Expected)
+		}
+	default:
+		return fmt.Errorf("unknown distribution %q", c.Distribution)
+	}
+	return nil
+}
+
+// sample draws an unclamped wait time from the configured distribution.
+func (c Config) sample() time.Duration {
+	r := c.rand()
+	e := float64(c.Expected)
+	switch c.Distribution {
+	case Uniform:
+		return toDuration(2 * e * r.Float64())
+	case Pareto:
+		// Inverse transform sampling. 1-Float64() is in (0, 1], which avoids
+		// dividing by zero.
+		scale := e * (c.Shape - 1) / c.Shape
+		return toDuration(scale / math.Pow(1-r.Float64(), 1/c.Shape))
+	case LogNormal:
+		mu := math.Log(e) - c.Shape*c.Shape/2
+		return toDuration(math.Exp(mu + c.Shape*r.NormFloat64()))
+	case ConstantJitter:
+		return c.Expected + toDuration((2*r.Float64()-1)*float64(c.Jitter))
+	default:
+		return toDuration(r.ExpFloat64() * e)
+	}
+}
+
+// toDuration converts f to a Duration, saturating instead of overflowing.
+func toDuration(f float64) time.Duration {
+	if f >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return time.Duration(f)
+}
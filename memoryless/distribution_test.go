@@ -0,0 +1,155 @@
+package memoryless_test
+
+import (
+	"context"
+	"flag"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/m-lab/go/memoryless"
+	"github.com/m-lab/go/rtx"
+)
+
+// sampleMean returns the mean of n wait times drawn from c, in units of
+// c.Expected, along with the smallest and largest wait times seen.
+func sampleMean(c memoryless.Config, n int) (mean float64, min, max time.Duration) {
+	sum := 0.0
+	min = time.Duration(math.MaxInt64)
+	for i := 0; i < n; i++ {
+		wt := c.WaitTime()
+		sum += float64(wt)
+		if wt < min {
+			min = wt
+		}
+		if wt > max {
+			max = wt
+		}
+	}
+	return sum / float64(n) / float64(c.Expected), min, max
+}
+
+func TestDistributions(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  memoryless.Config
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name:    "default",
+			config:  memoryless.Config{Expected: time.Second},
+			wantMax: time.Duration(math.MaxInt64),
+		},
+		{
+			name:    "exponential",
+			config:  memoryless.Config{Expected: time.Second, Distribution: memoryless.Exponential},
+			wantMax: time.Duration(math.MaxInt64),
+		},
+		{
+			name:    "uniform",
+			config:  memoryless.Config{Expected: time.Second, Distribution: memoryless.Uniform},
+			wantMax: 2 * time.Second,
+		},
+		{
+			name:    "pareto",
+			config:  memoryless.Config{Expected: time.Second, Distribution: memoryless.Pareto, Shape: 3},
+			wantMin: 2 * time.Second / 3,
+			wantMax: time.Duration(math.MaxInt64),
+		},
+		{
+			name:    "lognormal",
+			config:  memoryless.Config{Expected: time.Second, Distribution: memoryless.LogNormal, Shape: 0.5},
+			wantMax: time.Duration(math.MaxInt64),
+		},
+		{
+			name:    "constant-jitter",
+			config:  memoryless.Config{Expected: time.Second, Distribution: memoryless.ConstantJitter, Jitter: 100 * time.Millisecond},
+			wantMin: 900 * time.Millisecond,
+			wantMax: 1100 * time.Millisecond,
+		},
+		{
+			name:    "constant",
+			config:  memoryless.Config{Expected: time.Second, Distribution: memoryless.ConstantJitter},
+			wantMin: time.Second,
+			wantMax: time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rtx.Must(tt.config.Check(), "Bad config")
+			tt.config.Rand = rand.New(rand.NewSource(1))
+			mean, min, max := sampleMean(tt.config, 200000)
+			// With this many samples, the sample mean of every distribution
+			// above is well within 2% of the true mean.
+			if math.Abs(mean-1) > 0.02 {
+				t.Errorf("mean wait time = %v * Expected, want 1", mean)
+			}
+			if min < tt.wantMin || max > tt.wantMax {
+				t.Errorf("wait times in [%v, %v], want them in [%v, %v]", min, max, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestDistributionsAreClamped(t *testing.T) {
+	c := memoryless.Config{
+		Expected:     time.Second,
+		Min:          500 * time.Millisecond,
+		Max:          1500 * time.Millisecond,
+		Distribution: memoryless.Pareto,
+		Shape:        1.1,
+		Rand:         rand.New(rand.NewSource(1)),
+	}
+	_, min, max := sampleMean(c, 10000)
+	if min < c.Min || max > c.Max {
+		t.Errorf("wait times in [%v, %v], want them in [%v, %v]", min, max, c.Min, c.Max)
+	}
+}
+
+func TestDistributionTicker(t *testing.T) {
+	wt := time.Microsecond
+	ticker, err := memoryless.NewTicker(context.Background(), memoryless.Config{
+		Expected: wt, Distribution: memoryless.ConstantJitter, Jitter: wt / 2,
+	})
+	rtx.Must(err, "Could not make ticker")
+	defer ticker.Stop()
+	<-ticker.C
+}
+
+func TestBadDistributionArgs(t *testing.T) {
+	for _, c := range []memoryless.Config{
+		{Expected: time.Second, Distribution: "gaussian"},
+		{Expected: time.Second, Distribution: memoryless.Pareto},
+		{Expected: time.Second, Distribution: memoryless.Pareto, Shape: 1},
+		{Expected: time.Second, Distribution: memoryless.LogNormal},
+		{Expected: time.Second, Distribution: memoryless.LogNormal, Shape: -1},
+		{Expected: time.Second, Distribution: memoryless.LogNormal, Shape: math.NaN()},
+		{Expected: time.Second, Distribution: memoryless.ConstantJitter, Jitter: -1},
+		{Expected: time.Second, Distribution: memoryless.ConstantJitter, Jitter: 2 * time.Second},
+	} {
+		if c.Check() == nil {
+			t.Errorf("Should have had an error with config %+v", c)
+		}
+		if _, err := memoryless.NewTicker(context.Background(), c); err == nil {
+			t.Errorf("Should have had an error running config %+v", c)
+		}
+	}
+}
+
+func TestDistributionFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	d := memoryless.Exponential
+	fs.Var(&d, "distribution", "")
+	rtx.Must(fs.Parse([]string{"-distribution=lognormal"}), "Could not parse flags")
+	if d != memoryless.LogNormal || d.String() != "lognormal" || d.Get() != memoryless.LogNormal {
+		t.Errorf("Distribution flag = %v, want lognormal", d)
+	}
+	for _, s := range []string{"exponential", "uniform", "pareto", "lognormal", "constant-jitter"} {
+		rtx.Must(d.Set(s), "Could not set %q", s)
+	}
+	if d.Set("gaussian") == nil {
+		t.Error("Set() should have failed for an unknown distribution")
+	}
+}
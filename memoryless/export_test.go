@@ -0,0 +1,8 @@
+package memoryless
+
+import "time"
+
+// WaitTime exposes waittime for testing.
+func (c Config) WaitTime() time.Duration {
+	return c.waittime()
+}
@@ -0,0 +1,89 @@
+package memoryless
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/m-lab/go/host"
+)
+
+// Fleet describes a set of hosts that together should generate events as a
+// single Poisson process with a target rate, without any coordination between
+// the hosts.
+//
+// This works because the superposition of independent Poisson processes is a
+// Poisson process whose rate is the sum of their rates. Each host runs its own
+// exponential schedule at Rate/Hosts, using a source of randomness seeded from
+// its name, so that schedules are independent between hosts and reproducible
+// for each host.
+type Fleet struct {
+	// Rate is the target number of events per second across the whole fleet.
+	Rate float64
+	// Hosts is the number of hosts sharing the rate. If the actual number of
+	// hosts differs, the aggregate rate is scaled proportionally.
+	Hosts int
+	// Salt is mixed into the seed of every host, so that fleets running
+	// different schedules (e.g. different experiments) on the same hosts are
+	// independent of each other.
+	Salt string
+}
+
+// Check returns an error if the fleet makes no sense.
+func (f Fleet) Check() error {
+	if !(f.Rate > 0) || math.IsInf(f.Rate, 1) {
+		return errors.New("fleet Rate must be positive and finite")
+	}
+	if f.Hosts <= 0 {
+		return errors.New("fleet Hosts must be positive")
+	}
+	if f.expected() <= 0 {
+		return errors.New("fleet Rate per host is too high")
+	}
+	return nil
+}
+
+// expected returns the mean time between the events of each host.
+func (f Fleet) expected() time.Duration {
+	return toDuration(float64(f.Hosts) / f.Rate * float64(time.Second))
+}
+
+// Seed returns the deterministic seed of the schedule of the named host. It
+// depends on the service, machine, site, org and project of the name, so every
+// service on every machine gets its own schedule, but not on the random suffix
+// of managed instance groups.
+func (f Fleet) Seed(name host.Name) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(f.Salt))
+	h.Write([]byte{0})
+	h.Write([]byte(name.StringWithService()))
+	return int64(h.Sum64())
+}
+
+// Config returns the config for the schedule of the named host. The returned
+// config has its own Rand, so it must not be shared between concurrently
+// running Tickers. Setting Min or Max on the returned config biases the
+// aggregate process away from Poisson; see the caveats of Ticker.
+func (f Fleet) Config(name host.Name) (Config, error) {
+	if err := f.Check(); err != nil {
+		return Config{}, err
+	}
+	return Config{
+		Expected:     f.expected(),
+		Distribution: Exponential,
+		Rand:         rand.New(rand.NewSource(f.Seed(name))),
+	}, nil
+}
+
+// Run calls the given function repeatedly on the schedule of the named host.
+// It is a convenience wrapper around Config and Run.
+func (f Fleet) Run(ctx context.Context, name host.Name, fn func()) error {
+	c, err := f.Config(name)
+	if err != nil {
+		return err
+	}
+	return Run(ctx, fn, c)
+}
@@ -0,0 +1,144 @@
+package memoryless_test
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/m-lab/go/host"
+	"github.com/m-lab/go/memoryless"
+	"github.com/m-lab/go/rtx"
+)
+
+func waitsFor(f memoryless.Fleet, name host.Name, n int) []time.Duration {
+	c, err := f.Config(name)
+	rtx.Must(err, "Could not make config")
+	waits := make([]time.Duration, n)
+	for i := range waits {
+		waits[i] = c.WaitTime()
+	}
+	return waits
+}
+
+func TestFleetSeeds(t *testing.T) {
+	f := memoryless.Fleet{Rate: 1, Hosts: 4, Salt: "ndt"}
+	name, err := host.Parse("ndt-mlab1-lga01.mlab-oti.measurement-lab.org")
+	rtx.Must(err, "Could not parse name")
+	same := name
+	same.Suffix = "-d9h6"
+	otherService := name
+	otherService.Service = "wehe"
+	otherMachine := name
+	otherMachine.Machine = "mlab2"
+
+	want := fmt.Sprint(waitsFor(f, name, 10))
+	if got := fmt.Sprint(waitsFor(f, same, 10)); got != want {
+		t.Errorf("Schedules of the same host differ: %v != %v", got, want)
+	}
+	for _, n := range []host.Name{otherService, otherMachine} {
+		if got := fmt.Sprint(waitsFor(f, n, 10)); got == want {
+			t.Errorf("Schedule of %v is the same as that of %v", n.StringAll(), name.StringAll())
+		}
+	}
+	salted := f
+	salted.Salt = "wehe"
+	if salted.Seed(name) == f.Seed(name) {
+		t.Error("The salt should change the seed")
+	}
+}
+
+func TestFleetIsPoisson(t *testing.T) {
+	const (
+		hosts   = 20
+		rate    = 10.0
+		horizon = 2000 * time.Second
+	)
+	f := memoryless.Fleet{Rate: rate, Hosts: hosts}
+	var events []time.Duration
+	for i := 0; i < hosts; i++ {
+		c, err := f.Config(host.Name{Machine: "mlab1", Site: fmt.Sprintf("lga%02d", i), Project: "mlab-oti", Domain: "measurement-lab.org", Version: "v2"})
+		rtx.Must(err, "Could not make config")
+		for now := c.WaitTime(); now < horizon; now += c.WaitTime() {
+			events = append(events, now)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+
+	// The aggregate rate should be the target rate.
+	gotRate := float64(len(events)) / horizon.Seconds()
+	if math.Abs(gotRate-rate) > 0.03*rate {
+		t.Errorf("aggregate rate = %v, want %v", gotRate, rate)
+	}
+
+	// The aggregate inter-event times should be exponential: their mean
+	// should be 1/rate and their standard deviation should equal the mean.
+	sum, sumsq := 0.0, 0.0
+	for i := 1; i < len(events); i++ {
+		d := (events[i] - events[i-1]).Seconds()
+		sum += d
+		sumsq += d * d
+	}
+	n := float64(len(events) - 1)
+	mean := sum / n
+	cv := math.Sqrt(sumsq/n-mean*mean) / mean
+	if math.Abs(mean*rate-1) > 0.03 || math.Abs(cv-1) > 0.03 {
+		t.Errorf("inter-event times have mean %v and coefficient of variation %v, want %v and 1", mean, cv, 1/rate)
+	}
+
+	// The number of events per second should have variance equal to its mean.
+	counts := make([]float64, int(horizon.Seconds()))
+	for _, e := range events {
+		counts[int(e.Seconds())]++
+	}
+	sum, sumsq = 0, 0
+	for _, c := range counts {
+		sum += c
+		sumsq += c * c
+	}
+	mean = sum / float64(len(counts))
+	dispersion := (sumsq/float64(len(counts)) - mean*mean) / mean
+	if math.Abs(dispersion-1) > 0.1 {
+		t.Errorf("index of dispersion = %v, want 1", dispersion)
+	}
+}
+
+func TestFleetRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	f := memoryless.Fleet{Rate: 1e6, Hosts: 1}
+	err := f.Run(ctx, host.Name{}, func() {
+		calls++
+		if calls == 3 {
+			cancel()
+		}
+	})
+	rtx.Must(err, "Could not run")
+	if calls < 3 {
+		t.Errorf("f() was called %d times, want at least 3", calls)
+	}
+}
+
+func TestBadFleetArgs(t *testing.T) {
+	for _, f := range []memoryless.Fleet{
+		{},
+		{Rate: 1},
+		{Hosts: 1},
+		{Rate: -1, Hosts: 1},
+		{Rate: math.NaN(), Hosts: 1},
+		{Rate: math.Inf(1), Hosts: 1},
+		{Rate: 1e10, Hosts: 1},
+	} {
+		if f.Check() == nil {
+			t.Errorf("Should have had an error with fleet %+v", f)
+		}
+		if _, err := f.Config(host.Name{}); err == nil {
+			t.Errorf("Config() should have failed with fleet %+v", f)
+		}
+		if err := f.Run(context.Background(), host.Name{}, func() {}); err == nil {
+			t.Errorf("Run() should have failed with fleet %+v", f)
+		}
+	}
+}
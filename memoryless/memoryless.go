@@ -5,7 +5,6 @@ package memoryless
 import (
 	"context"
 	"fmt"
-	"math/rand"
 	"time"
 )
 
@@ -20,12 +19,24 @@ import (
 type Config struct {
 	// Expected records the expected/mean/average amount of time between runs.
 	Expected time.Duration
-	// Min provides clamping of the randomly produced value. All timers will wait
-	// at least Min time.
+	// Distribution selects the distribution of the time between runs. If it is
+	// unset, the exponential distribution is used.
+	Distribution Distribution
+	// Shape is the shape parameter of the Pareto and LogNormal distributions.
+	// It is ignored by all other distributions.
+	Shape float64
+	// Jitter is the largest deviation from Expected produced by the
+	// ConstantJitter distribution. It is ignored by all other distributions.
+	Jitter time.Duration
+	// Min provides truncation of the randomly produced value. All timers will
+	// wait at least Min time.
 	Min time.Duration
-	// Max provides clamping of the randomly produced value. All timers will take
-	// at most Max time.
+	// Max provides truncation of the randomly produced value. All timers will
+	// take at most Max time.
 	Max time.Duration
+	// Truncation selects how the randomly produced value is kept between Min
+	// and Max. If it is unset, values are clamped.
+	Truncation Truncation
 
 	// Once is provided as a helper, because frequently for unit testing and
 	// integration testing, you only want the "Forever" loop to run once.
@@ -33,17 +44,43 @@ type Config struct {
 	// The zero value of this struct has Once set to false, which means the value
 	// only needs to be set explicitly in codepaths where it might be true.
 	Once bool
+
+	// Rand, if non-nil, is the source of randomness for wait times. Seeding it
+	// makes the sequence of wait times deterministic. The global math/rand
+	// source is used if it is nil. A Rand is used by the goroutine of a single
+	// Ticker, so a Rand that is not safe for concurrent use (like *rand.Rand)
+	// must not be shared between Configs used concurrently.
+	Rand Rand
+
+	// Clock, if non-nil, provides the time and timers used by Ticker, Run,
+	// NewClockTimer and ClockAfterFunc. The system clock is used if it is nil.
+	Clock Clock
 }
 
-func (c Config) waittime() time.Duration {
-	wt := time.Duration(rand.ExpFloat64() * float64(c.Expected))
-	if wt < c.Min {
-		wt = c.Min
+func (c Config) rand() Rand {
+	if c.Rand == nil {
+		return globalRand{}
 	}
-	if c.Max != 0 && wt > c.Max {
-		wt = c.Max
+	return c.Rand
+}
+
+func (c Config) clock() Clock {
+	if c.Clock == nil {
+		return realClock{}
+	}
+	return c.Clock
+}
+
+func (c Config) waittime() time.Duration {
+	switch c.Truncation {
+	case Resample:
+		return c.resample(c.sample)
+	case Shift:
+		s := c.shifted()
+		return c.resample(func() time.Duration { return c.Min + s.sample() })
+	default:
+		return c.clamp(c.sample())
 	}
-	return wt
 }
 
 // Check whether the config contrains sensible values. It return an error if the
@@ -55,24 +92,49 @@ func (c Config) Check() error {
 				"but that is not true for Min(%v) Expected(%v) Max(%v).",
 			c.Min, c.Expected, c.Max)
 	}
-	return nil
+	if err := c.checkDistribution(); err != nil {
+		return err
+	}
+	return c.checkTruncation()
 }
 
 // newTimer constructs and returns a timer. This function assumes that the
 // config has no errors.
-func newTimer(c Config) *time.Timer {
-	return time.NewTimer(c.waittime())
+func newTimer(c Config) Timer {
+	return c.clock().NewTimer(c.waittime())
+}
+
+// checkNoClock returns an error if the config has a Clock, which functions
+// returning a *time.Timer cannot use.
+func checkNoClock(c Config, alternative string) error {
+	if c.Clock != nil {
+		return fmt.Errorf("a *time.Timer cannot use Config.Clock, use %s instead", alternative)
+	}
+	return nil
 }
 
 // NewTimer constructs a single-shot time.Timer that, if repeatedly used to
 // construct a series of timers, will ensure that the resulting events conform
 // to the memoryless distribution. For more on how this could and should be
 // used, see the comments to Ticker. It is intended to be a drop-in replacement
-// for time.NewTimer.
+// for time.NewTimer. Because it returns a *time.Timer, it always uses the
+// system clock, and returns an error if Config.Clock is set.
 func NewTimer(c Config) (*time.Timer, error) {
 	if err := c.Check(); err != nil {
 		return nil, err
 	}
+	if err := checkNoClock(c, "NewClockTimer"); err != nil {
+		return nil, err
+	}
+
+	return time.NewTimer(c.waittime()), nil
+}
+
+// NewClockTimer is like NewTimer, but the timer is created by Config.Clock.
+func NewClockTimer(c Config) (Timer, error) {
+	if err := c.Check(); err != nil {
+		return nil, err
+	}
 
 	return newTimer(c), nil
 }
@@ -81,28 +143,47 @@ func NewTimer(c Config) (*time.Timer, error) {
 // construct a series of timers, will ensure that the resulting events conform
 // to the memoryless distribution. For more on how this could and should be
 // used, see the comments to Ticker. It is intended to be a drop-in replacement
-// for time.AfterFunc.
+// for time.AfterFunc. Because it returns a *time.Timer, it always uses the
+// system clock, and returns an error if Config.Clock is set.
 func AfterFunc(c Config, f func()) (*time.Timer, error) {
 	if err := c.Check(); err != nil {
 		return nil, err
 	}
+	if err := checkNoClock(c, "ClockAfterFunc"); err != nil {
+		return nil, err
+	}
 
 	return time.AfterFunc(c.waittime(), f), nil
 }
 
+// ClockAfterFunc is like AfterFunc, but the timer is created by Config.Clock.
+func ClockAfterFunc(c Config, f func()) (Timer, error) {
+	if err := c.Check(); err != nil {
+		return nil, err
+	}
+
+	return c.clock().AfterFunc(c.waittime(), f), nil
+}
+
 // Ticker is a struct that waits a config.Expected amount of time on average
 // between sends down the channel C. It has the same interface and requirements
 // as time.Ticker. Every Ticker created must have its Stop() method called or it
 // will leak a goroutine.
 //
-// The inter-send time is a random variable governed by the exponential
-// distribution and will generate a memoryless (Poisson) distribution of channel
-// reads over time, ensuring that a measurement scheme using this ticker has the
-// PASTA property (Poisson Arrivals See Time Averages). This statistical
-// guarantee is subject to two caveats:
+// By default, the inter-send time is a random variable governed by the
+// exponential distribution and will generate a memoryless (Poisson)
+// distribution of channel reads over time, ensuring that a measurement scheme
+// using this ticker has the PASTA property (Poisson Arrivals See Time
+// Averages). If config.Distribution selects another distribution, the ticker
+// has the same semantics but none of these statistical guarantees.
+//
+// With the exponential distribution, this statistical guarantee is subject to
+// two caveats:
 //
 // Caveat 1 is that, in a nod to the realities of systems needing to have
 // guarantees, we allow the random wait time to be clamped both above and below.
+// (Setting config.Truncation to Resample or Shift avoids clamping, at the cost
+// of changing the distribution in other ways. See their documentation.)
 // This means that channel events will be at least config.Min and at most
 // config.Max apart in time. This clamping causes bias in the timing. For use of
 // Ticker to be statistically sensible, the clamping should not be too extreme.
@@ -144,14 +225,14 @@ func (t *Ticker) singleIteration(ctx context.Context) {
 		// that it could be true that the timer is done AND the context is canceled,
 		// and we have no guarantee that in that case the canceled context case will
 		// be the one that is selected.
-	case <-timer.C:
+	case <-timer.C():
 	}
 	// Just like time.Ticker, writes to the channel are non-blocking. If a user of
 	// this module can't keep up with the timer they set, that's on them. There
 	// are some potential pathological cases associated with queueing events in
 	// the channel, and we want to avoid them.
 	select {
-	case t.writeChan <- time.Now():
+	case t.writeChan <- t.config.clock().Now():
 	default:
 	}
 }
@@ -2,11 +2,13 @@ package memoryless_test
 
 import (
 	"context"
+	"math/rand"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/m-lab/go/memoryless"
+	"github.com/m-lab/go/memoryless/memorylesstest"
 	"github.com/m-lab/go/rtx"
 )
 
@@ -151,3 +153,109 @@ func TestAfterFunc(t *testing.T) {
 		t.Error("It should be:", start, "<=", funcTime, "<=", end)
 	}
 }
+
+func TestClockErrors(t *testing.T) {
+	c := memoryless.Config{Expected: time.Second, Clock: memorylesstest.NewFakeClock(time.Now())}
+	if _, err := memoryless.NewTimer(c); err == nil {
+		t.Error("NewTimer() should not accept a Clock")
+	}
+	if _, err := memoryless.AfterFunc(c, func() {}); err == nil {
+		t.Error("AfterFunc() should not accept a Clock")
+	}
+	bad := memoryless.Config{Expected: -1}
+	if _, err := memoryless.NewClockTimer(bad); err == nil {
+		t.Error("NewClockTimer() should fail with a bad config")
+	}
+	if _, err := memoryless.ClockAfterFunc(bad, func() {}); err == nil {
+		t.Error("ClockAfterFunc() should fail with a bad config")
+	}
+}
+
+// expectedWaits returns the first n wait times a Config with the given
+// parameters and seed should produce.
+func expectedWaits(seed int64, expected, max time.Duration, n int) []time.Duration {
+	r := rand.New(rand.NewSource(seed))
+	waits := make([]time.Duration, n)
+	for i := range waits {
+		waits[i] = time.Duration(r.ExpFloat64() * float64(expected))
+		if waits[i] > max {
+			waits[i] = max
+		}
+	}
+	return waits
+}
+
+func TestTickerWithFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := memorylesstest.NewFakeClock(start)
+	c := memoryless.Config{
+		Expected: time.Minute,
+		Max:      2 * time.Minute,
+		Rand:     rand.New(rand.NewSource(17)),
+		Clock:    clock,
+	}
+	ticker, err := memoryless.NewTicker(context.Background(), c)
+	rtx.Must(err, "Could not make ticker")
+	defer ticker.Stop()
+
+	now := start
+	for i, wt := range expectedWaits(17, time.Minute, 2*time.Minute, 20) {
+		now = now.Add(wt)
+		go func() {
+			clock.BlockUntil(1)
+			clock.AdvanceToNext()
+		}()
+		if got := <-ticker.C; !got.Equal(now) {
+			t.Fatalf("tick %d at %v, want %v", i, got, now)
+		}
+	}
+}
+
+func TestClockTimersWithFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := memorylesstest.NewFakeClock(start)
+	waits := expectedWaits(3, time.Second, time.Hour, 2)
+	c := memoryless.Config{
+		Expected: time.Second,
+		Rand:     rand.New(rand.NewSource(3)),
+		Clock:    clock,
+	}
+	timer, err := memoryless.NewClockTimer(c)
+	rtx.Must(err, "Could not make timer")
+	if d := clock.AdvanceToNext(); d != waits[0] {
+		t.Errorf("timer waited %v, want %v", d, waits[0])
+	}
+	<-timer.C()
+
+	done := make(chan struct{})
+	_, err = memoryless.ClockAfterFunc(c, func() { close(done) })
+	rtx.Must(err, "Could not make timer")
+	if d := clock.AdvanceToNext(); d != waits[1] {
+		t.Errorf("AfterFunc waited %v, want %v", d, waits[1])
+	}
+	<-done
+}
+
+func TestRunWithFakeClock(t *testing.T) {
+	clock := memorylesstest.NewFakeClock(time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	f := func() {
+		calls++
+		if calls == 5 {
+			cancel()
+		}
+	}
+	go func() {
+		for ctx.Err() == nil {
+			if clock.AdvanceToNext() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	c := memoryless.Config{Expected: time.Hour, Clock: clock}
+	rtx.Must(memoryless.Run(ctx, f, c), "Could not run")
+	if calls < 5 {
+		t.Errorf("f() was called %d times, want at least 5", calls)
+	}
+}
@@ -0,0 +1,161 @@
+// Package memorylesstest provides a fake memoryless.Clock, so that tests of
+// code scheduled with the memoryless package can control time exactly instead
+// of waiting for real timers.
+package memorylesstest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/m-lab/go/memoryless"
+)
+
+// FakeClock is a memoryless.Clock whose time only changes when Advance is
+// called. It is safe for concurrent use.
+//
+// Code under test typically creates timers from another goroutine, so tests
+// should call BlockUntil before Advance to be sure the timers they expect to
+// fire have been created. A Ticker only delivers a tick if its channel is
+// being read when the tick fires, so read the channel in one goroutine while
+// advancing the clock in another.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	timers  []*fakeTimer
+	counter int
+}
+
+// NewFakeClock returns a FakeClock whose current time is start.
+func NewFakeClock(start time.Time) *FakeClock {
+	c := &FakeClock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// fakeTimer is a memoryless.Timer created by a FakeClock.
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	// seq breaks ties between timers with the same deadline, so that they fire
+	// in the order they were created.
+	seq int
+	c   chan time.Time
+	f   func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.remove(t)
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer creates a timer that fires once the clock has been advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) memoryless.Timer {
+	return c.add(d, nil)
+}
+
+// AfterFunc creates a timer that calls f in its own goroutine once the clock
+// has been advanced by d.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) memoryless.Timer {
+	return c.add(d, f)
+}
+
+func (c *FakeClock) add(d time.Duration, f func()) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{
+		clock:    c,
+		deadline: c.now.Add(d),
+		seq:      c.counter,
+		c:        make(chan time.Time, 1),
+		f:        f,
+	}
+	c.counter++
+	c.timers = append(c.timers, t)
+	sort.Slice(c.timers, func(i, j int) bool {
+		if c.timers[i].deadline.Equal(c.timers[j].deadline) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	c.cond.Broadcast()
+	return t
+}
+
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.timers {
+		if c.timers[i] == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// Advance moves the clock forward by d, firing every timer whose deadline is
+// reached, in deadline order. Each timer channel receives the timer's
+// deadline, but Advance holds the clock's lock until every timer has fired, so
+// Now, and so any AfterFunc callback, only ever observes the final time.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].deadline.After(end) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.deadline
+		c.cond.Broadcast()
+		if t.f != nil {
+			go t.f()
+		} else {
+			t.c <- t.deadline
+		}
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// AdvanceToNext moves the clock forward to the deadline of the earliest
+// pending timer, firing it, and returns how far the clock moved. If there are
+// no pending timers, it does nothing and returns zero.
+func (c *FakeClock) AdvanceToNext() time.Duration {
+	c.mu.Lock()
+	if len(c.timers) == 0 {
+		c.mu.Unlock()
+		return 0
+	}
+	d := c.timers[0].deadline.Sub(c.now)
+	c.mu.Unlock()
+	c.Advance(d)
+	return d
+}
+
+// Pending returns the number of timers that have not yet fired or been
+// stopped.
+func (c *FakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil blocks until at least n timers are pending.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
@@ -0,0 +1,81 @@
+package memorylesstest_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/m-lab/go/memoryless/memorylesstest"
+)
+
+var start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock_NewTimer(t *testing.T) {
+	c := memorylesstest.NewFakeClock(start)
+	t2 := c.NewTimer(2 * time.Second)
+	t1 := c.NewTimer(time.Second)
+	t3 := c.NewTimer(3 * time.Second)
+	if c.Pending() != 3 {
+		t.Errorf("Pending() = %d, want 3", c.Pending())
+	}
+	if !t3.Stop() {
+		t.Error("Stop() of a pending timer should return true")
+	}
+	if t3.Stop() {
+		t.Error("Stop() of a stopped timer should return false")
+	}
+
+	c.Advance(1500 * time.Millisecond)
+	if got := <-t1.C(); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("timer fired at %v, want %v", got, start.Add(time.Second))
+	}
+	select {
+	case <-t2.C():
+		t.Error("timer fired early")
+	default:
+	}
+	if !c.Now().Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("Now() = %v", c.Now())
+	}
+
+	if d := c.AdvanceToNext(); d != 500*time.Millisecond {
+		t.Errorf("AdvanceToNext() = %v, want 500ms", d)
+	}
+	if got := <-t2.C(); !got.Equal(start.Add(2 * time.Second)) {
+		t.Errorf("timer fired at %v, want %v", got, start.Add(2*time.Second))
+	}
+	if t2.Stop() {
+		t.Error("Stop() of a fired timer should return false")
+	}
+	if d := c.AdvanceToNext(); d != 0 {
+		t.Errorf("AdvanceToNext() with no timers = %v, want 0", d)
+	}
+}
+
+func TestFakeClock_AfterFunc(t *testing.T) {
+	c := memorylesstest.NewFakeClock(start)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	var at time.Time
+	c.AfterFunc(time.Minute, func() {
+		at = c.Now()
+		wg.Done()
+	})
+	c.Advance(time.Minute)
+	wg.Wait()
+	if !at.Equal(start.Add(time.Minute)) {
+		t.Errorf("AfterFunc ran at %v, want %v", at, start.Add(time.Minute))
+	}
+}
+
+func TestFakeClock_BlockUntil(t *testing.T) {
+	c := memorylesstest.NewFakeClock(start)
+	done := make(chan struct{})
+	go func() {
+		c.BlockUntil(2)
+		close(done)
+	}()
+	c.NewTimer(time.Second)
+	c.NewTimer(time.Second)
+	<-done
+}
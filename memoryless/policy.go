@@ -0,0 +1,142 @@
+package memoryless
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrTooManyErrors is returned by RunE, wrapped along with the last error,
+// when the function fails Policy.MaxConsecutiveErrors times in a row.
+var ErrTooManyErrors = errors.New("too many consecutive errors")
+
+// Policy controls how RunE reacts to failed iterations.
+type Policy struct {
+	// BackoffFactor, if greater than 1, multiplies the wait times of the
+	// config after every consecutive failure, so that the k-th retry after a
+	// failure waits BackoffFactor^k times longer on average. Because the wait
+	// times remain random, the backoff is jittered. A successful iteration
+	// resets the wait times. If it is 0 or 1, failures do not change the wait
+	// times.
+	BackoffFactor float64
+	// MaxBackoff, if positive, caps the expected wait time during backoff.
+	MaxBackoff time.Duration
+	// MaxConsecutiveErrors, if positive, makes RunE stop and return an error
+	// after that many consecutive failed iterations.
+	MaxConsecutiveErrors int
+	// Timeout bounds the context passed to every iteration. If it is zero, the
+	// Expected time of the config is used. If it is negative, iterations have
+	// no timeout beyond that of the context passed to RunE.
+	Timeout time.Duration
+}
+
+// Check returns an error if the policy makes no sense.
+func (p Policy) Check() error {
+	if p.BackoffFactor != 0 && !(p.BackoffFactor >= 1) || math.IsInf(p.BackoffFactor, 1) {
+		return fmt.Errorf("BackoffFactor(%v) should be 0 or at least 1", p.BackoffFactor)
+	}
+	if p.MaxBackoff < 0 {
+		return fmt.Errorf("MaxBackoff(%v) should not be negative", p.MaxBackoff)
+	}
+	if p.MaxConsecutiveErrors < 0 {
+		return fmt.Errorf("MaxConsecutiveErrors(%d) should not be negative", p.MaxConsecutiveErrors)
+	}
+	return nil
+}
+
+// backoff returns the config to use after the given number of consecutive
+// failures.
+func (p Policy) backoff(c Config, failures int) Config {
+	if failures == 0 || p.BackoffFactor <= 1 {
+		return c
+	}
+	factor := math.Pow(p.BackoffFactor, float64(failures))
+	if p.MaxBackoff > 0 && float64(c.Expected)*factor > float64(p.MaxBackoff) {
+		factor = math.Max(1, float64(p.MaxBackoff)/float64(c.Expected))
+	}
+	return c.scaled(factor)
+}
+
+// scaled returns the config with all its durations multiplied by factor.
+func (c Config) scaled(factor float64) Config {
+	s := c
+	s.Expected = toDuration(float64(c.Expected) * factor)
+	s.Min = toDuration(float64(c.Min) * factor)
+	s.Max = toDuration(float64(c.Max) * factor)
+	s.Jitter = toDuration(float64(c.Jitter) * factor)
+	return s
+}
+
+// withTimeout returns a context that is canceled after d, as measured by the
+// clock of the config.
+func (c Config) withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if c.Clock == nil {
+		return context.WithTimeout(ctx, d)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	timer := c.Clock.AfterFunc(d, func() { cancel(context.DeadlineExceeded) })
+	return ctx, func() {
+		timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// call runs a single iteration of f.
+func (p Policy) call(ctx context.Context, c Config, f func(context.Context) error) error {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = c.Expected
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = c.withTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return f(ctx)
+}
+
+// RunE calls the given function repeatedly, waiting between calls like Run,
+// and uses the policy to react to the errors it returns. It returns nil when
+// the context is canceled, and an error wrapping ErrTooManyErrors if the
+// policy gives up. If c.Once is set, it returns the error of the only call.
+//
+// Unlike Run, whose ticker keeps running while the function is called, RunE
+// only starts the wait for the next call once each call returns. The time
+// spent in calls is therefore added to the wait times, including backoff,
+// rather than counted in them.
+// If the config uses a custom Clock, per-iteration timeouts are measured by
+// that clock, and context.Cause reports context.DeadlineExceeded when they
+// expire.
+func RunE(ctx context.Context, f func(context.Context) error, c Config, p Policy) error {
+	if err := c.Check(); err != nil {
+		return err
+	}
+	if err := p.Check(); err != nil {
+		return err
+	}
+	failures := 0
+	for ctx.Err() == nil {
+		timer := newTimer(p.backoff(c, failures))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C():
+		}
+		err := p.call(ctx, c, f)
+		if c.Once {
+			return err
+		}
+		if err == nil {
+			failures = 0
+			continue
+		}
+		failures++
+		if p.MaxConsecutiveErrors > 0 && failures >= p.MaxConsecutiveErrors {
+			return fmt.Errorf("%w (%d): %w", ErrTooManyErrors, failures, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package memoryless_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/memoryless"
+	"github.com/m-lab/go/memoryless/memorylesstest"
+)
+
+func TestRunEBackoff(t *testing.T) {
+	clock := memorylesstest.NewFakeClock(time.Now())
+	// A constant distribution makes every wait time predictable.
+	c := memoryless.Config{Expected: time.Second, Distribution: memoryless.ConstantJitter, Clock: clock}
+	p := memoryless.Policy{
+		BackoffFactor:        2,
+		MaxBackoff:           3 * time.Second,
+		MaxConsecutiveErrors: 3,
+		Timeout:              -1,
+	}
+	fail := errors.New("failure for testing")
+	results := []error{fail, fail, nil, fail, fail, fail, nil}
+	calls := 0
+	f := func(context.Context) error {
+		calls++
+		return results[calls-1]
+	}
+	wantWaits := []time.Duration{1, 2, 3, 1, 2, 3}
+	waits := make(chan time.Duration, len(wantWaits))
+	go func() {
+		for range wantWaits {
+			clock.BlockUntil(1)
+			waits <- clock.AdvanceToNext() / time.Second
+		}
+		close(waits)
+	}()
+
+	err := memoryless.RunE(context.Background(), f, c, p)
+	if !errors.Is(err, memoryless.ErrTooManyErrors) || !errors.Is(err, fail) {
+		t.Errorf("RunE() = %v, want an error wrapping %v and %v", err, memoryless.ErrTooManyErrors, fail)
+	}
+	if calls != 6 {
+		t.Errorf("f() was called %d times, want 6", calls)
+	}
+	var got []time.Duration
+	for w := range waits {
+		got = append(got, w)
+	}
+	if diff := deep.Equal(got, wantWaits); diff != nil {
+		t.Errorf("wait times in seconds differ: %v", diff)
+	}
+}
+
+func TestRunETimeoutWithFakeClock(t *testing.T) {
+	clock := memorylesstest.NewFakeClock(time.Now())
+	c := memoryless.Config{Expected: time.Second, Once: true, Clock: clock}
+	go func() {
+		// The first timer is the wait, the second is the timeout.
+		for i := 0; i < 2; i++ {
+			clock.BlockUntil(1)
+			clock.AdvanceToNext()
+		}
+	}()
+	err := memoryless.RunE(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return context.Cause(ctx)
+	}, c, memoryless.Policy{Timeout: time.Minute})
+	if err != context.DeadlineExceeded {
+		t.Errorf("RunE() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRunETimeoutDefaultsToExpected(t *testing.T) {
+	c := memoryless.Config{Expected: time.Millisecond, Once: true}
+	err := memoryless.RunE(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, c, memoryless.Policy{})
+	if err != context.DeadlineExceeded {
+		t.Errorf("RunE() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRunEUntilCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	f := func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("A negative Timeout should not set a deadline")
+		}
+		calls++
+		if calls == 5 {
+			cancel()
+		}
+		return errors.New("failures do not stop RunE by default")
+	}
+	c := memoryless.Config{Expected: time.Microsecond}
+	if err := memoryless.RunE(ctx, f, c, memoryless.Policy{BackoffFactor: 1.5, Timeout: -1}); err != nil {
+		t.Errorf("RunE() = %v, want nil", err)
+	}
+	if calls != 5 {
+		t.Errorf("f() was called %d times, want 5", calls)
+	}
+
+	// A canceled context stops RunE during the wait.
+	if err := memoryless.RunE(ctx, f, memoryless.Config{Expected: time.Hour}, memoryless.Policy{}); err != nil {
+		t.Errorf("RunE() = %v, want nil", err)
+	}
+}
+
+func TestRunEBadArgs(t *testing.T) {
+	f := func(context.Context) error { return nil }
+	if memoryless.RunE(context.Background(), f, memoryless.Config{Expected: -1}, memoryless.Policy{}) == nil {
+		t.Error("RunE() should fail with a bad config")
+	}
+	for _, p := range []memoryless.Policy{
+		{BackoffFactor: 0.5},
+		{BackoffFactor: -1},
+		{BackoffFactor: math.NaN()},
+		{BackoffFactor: math.Inf(1)},
+		{MaxBackoff: -1},
+		{MaxConsecutiveErrors: -1},
+	} {
+		if p.Check() == nil {
+			t.Errorf("Should have had an error with policy %+v", p)
+		}
+		if memoryless.RunE(context.Background(), f, memoryless.Config{Expected: time.Second}, p) == nil {
+			t.Errorf("RunE() should fail with policy %+v", p)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package memoryless
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	ticksDelivered = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "memoryless_ticks_delivered_total",
+			Help: "The number of ticks delivered by each ReportingTicker.",
+		},
+		[]string{"ticker"},
+	)
+	ticksSkipped = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "memoryless_ticks_skipped_total",
+			Help: "The number of ticks dropped by each ReportingTicker because the reader was not ready.",
+		},
+		[]string{"ticker"},
+	)
+	tickLateness = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "memoryless_tick_lateness_seconds",
+			Help:    "How long after their scheduled time the ticks of each ReportingTicker were delivered.",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		},
+		[]string{"ticker"},
+	)
+)
+
+// Tick is the value delivered by a ReportingTicker.
+type Tick struct {
+	// Scheduled is the time at which the tick was due.
+	Scheduled time.Time
+	// Delivered is the time at which the tick was sent on the channel.
+	Delivered time.Time
+	// Skipped is the number of ticks that were dropped since the previous
+	// delivered tick because the reader was not ready to receive them.
+	Skipped int
+}
+
+// Lateness returns how long after its scheduled time the tick was delivered.
+func (t Tick) Lateness() time.Duration {
+	return t.Delivered.Sub(t.Scheduled)
+}
+
+// ReportingTicker is a Ticker that delivers a Tick instead of a time.Time, so
+// that readers can tell when they are not keeping up with the schedule. Like
+// Ticker, writes to the channel are non-blocking and ticks the reader is not
+// ready for are dropped, but a ReportingTicker counts the dropped ticks and
+// reports them in the next delivered Tick. Lateness is caused by the ticking
+// goroutine itself running late (e.g. because the process is overloaded),
+// while skipped ticks are caused by the reader running late.
+//
+// Every ReportingTicker also exports Prometheus metrics, labeled by the name
+// passed to NewReportingTicker, counting delivered and skipped ticks and
+// recording the lateness of delivered ticks. Every ReportingTicker created
+// must have its Stop() method called or it will leak a goroutine.
+type ReportingTicker struct {
+	C         <-chan Tick // The channel on which the ticks are delivered.
+	name      string
+	config    Config
+	writeChan chan<- Tick
+	cancel    func()
+}
+
+// NewReportingTicker creates a new ReportingTicker, with ticks distributed
+// in time like those of a Ticker with the same config. The name is used to
+// label the metrics of the ticker.
+func NewReportingTicker(ctx context.Context, name string, config Config) (*ReportingTicker, error) {
+	if err := config.Check(); err != nil {
+		return nil, err
+	}
+	c := make(chan Tick)
+	ctx, cancel := context.WithCancel(ctx)
+	ticker := &ReportingTicker{
+		C:         c,
+		name:      name,
+		config:    config,
+		writeChan: c,
+		cancel:    cancel,
+	}
+	// Initialize the counters so that they are exported before the first tick.
+	ticksDelivered.WithLabelValues(name)
+	ticksSkipped.WithLabelValues(name)
+	go ticker.runTicker(ctx)
+	return ticker, nil
+}
+
+// singleIteration waits for the next tick and tries to deliver it, returning
+// the number of ticks skipped after it.
+func (t *ReportingTicker) singleIteration(ctx context.Context, skipped int) int {
+	clock := t.config.clock()
+	wt := t.config.waittime()
+	scheduled := clock.Now().Add(wt)
+	timer := clock.NewTimer(wt)
+	defer timer.Stop()
+	// As in Ticker.singleIteration, there is no guarantee about which case is
+	// selected if both are ready.
+	select {
+	case <-ctx.Done():
+		return skipped
+	case <-timer.C():
+	}
+	tick := Tick{
+		Scheduled: scheduled,
+		Delivered: clock.Now(),
+		Skipped:   skipped,
+	}
+	select {
+	case t.writeChan <- tick:
+		ticksDelivered.WithLabelValues(t.name).Inc()
+		tickLateness.WithLabelValues(t.name).Observe(tick.Lateness().Seconds())
+		return 0
+	default:
+		ticksSkipped.WithLabelValues(t.name).Inc()
+		return skipped + 1
+	}
+}
+
+func (t *ReportingTicker) runTicker(ctx context.Context) {
+	// No matter what, when this function exits the channel should never be written to again.
+	defer close(t.writeChan)
+
+	if t.config.Once {
+		if ctx.Err() == nil {
+			t.singleIteration(ctx, 0)
+		}
+		return
+	}
+
+	skipped := 0
+	for ctx.Err() == nil {
+		skipped = t.singleIteration(ctx, skipped)
+	}
+}
+
+// Stop the ticker goroutine.
+func (t *ReportingTicker) Stop() {
+	t.cancel()
+}
@@ -0,0 +1,105 @@
+package memoryless_test
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/m-lab/go/memoryless"
+	"github.com/m-lab/go/memoryless/memorylesstest"
+	"github.com/m-lab/go/prometheusx/promtest"
+	"github.com/m-lab/go/rtx"
+)
+
+func TestReportingTickerWithFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := memorylesstest.NewFakeClock(start)
+	c := memoryless.Config{
+		Expected: time.Minute,
+		Max:      2 * time.Minute,
+		Rand:     rand.New(rand.NewSource(5)),
+		Clock:    clock,
+	}
+	ticker, err := memoryless.NewReportingTicker(context.Background(), "test", c)
+	rtx.Must(err, "Could not make ticker")
+	defer ticker.Stop()
+	waits := expectedWaits(5, time.Minute, 2*time.Minute, 6)
+
+	// A tick delivered to a waiting reader is on time.
+	now := start.Add(waits[0])
+	go func() {
+		clock.BlockUntil(1)
+		clock.AdvanceToNext()
+	}()
+	tick := <-ticker.C
+	if !tick.Scheduled.Equal(now) || tick.Lateness() != 0 || tick.Skipped != 0 {
+		t.Errorf("tick = %+v, want one on time at %v", tick, now)
+	}
+
+	// Ticks are dropped and counted while nobody reads from the channel.
+	for _, wt := range waits[1:4] {
+		now = now.Add(wt)
+		clock.BlockUntil(1)
+		clock.AdvanceToNext()
+	}
+	// Once the next timer exists, the last tick has been dropped.
+	clock.BlockUntil(1)
+
+	// Advancing the clock past the deadline makes the ticking goroutine run
+	// late, because it sees the time at the end of the advance.
+	now = now.Add(waits[4])
+	go clock.Advance(waits[4] + time.Second)
+	tick = <-ticker.C
+	want := memoryless.Tick{Scheduled: now, Delivered: now.Add(time.Second), Skipped: 3}
+	if !tick.Scheduled.Equal(want.Scheduled) || !tick.Delivered.Equal(want.Delivered) || tick.Skipped != want.Skipped {
+		t.Errorf("tick = %+v, want %+v", tick, want)
+	}
+	if tick.Lateness() != time.Second {
+		t.Errorf("Lateness() = %v, want 1s", tick.Lateness())
+	}
+}
+
+func TestReportingTicker(t *testing.T) {
+	wt := time.Microsecond
+	ticker, err := memoryless.NewReportingTicker(context.Background(), "real", memoryless.Config{Expected: wt, Min: wt, Max: wt})
+	rtx.Must(err, "Could not make ticker")
+	for i := 0; i < 5; i++ {
+		tick := <-ticker.C
+		if tick.Delivered.Before(tick.Scheduled) {
+			t.Errorf("tick %+v was delivered early", tick)
+		}
+	}
+	ticker.Stop()
+	for range ticker.C {
+		// Drain the channel until the ticker closes it.
+	}
+	promtest.LintMetrics(t)
+}
+
+func TestReportingTickerOnce(t *testing.T) {
+	ticker, err := memoryless.NewReportingTicker(context.Background(), "once", memoryless.Config{Expected: time.Microsecond, Once: true})
+	rtx.Must(err, "Could not make ticker")
+	defer ticker.Stop()
+	count := 0
+	for range ticker.C {
+		count++
+	}
+	if count > 1 {
+		t.Errorf("Once ticker delivered %d ticks, want at most 1", count)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ticker, err = memoryless.NewReportingTicker(ctx, "once", memoryless.Config{Expected: time.Microsecond, Once: true})
+	rtx.Must(err, "Could not make ticker")
+	for range ticker.C {
+		t.Error("A canceled ticker should deliver no ticks")
+	}
+}
+
+func TestReportingTickerBadArgs(t *testing.T) {
+	if _, err := memoryless.NewReportingTicker(context.Background(), "bad", memoryless.Config{Expected: -1}); err == nil {
+		t.Error("NewReportingTicker() should fail with a bad config")
+	}
+}
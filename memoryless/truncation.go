@@ -0,0 +1,102 @@
+package memoryless
+
+import (
+	"fmt"
+	"time"
+)
+
+// Truncation is an enum, suitable for use as a command-line flag, that selects
+// how wait times are kept between Config.Min and Config.Max.
+type Truncation string
+
+var (
+	// Clamp replaces wait times below Min with Min and above Max with Max. It
+	// is the default. Clamping puts a spike of probability at each bound,
+	// which breaks the memoryless property if the bounds are often reached.
+	Clamp = Truncation("clamp")
+	// Resample draws new wait times until one falls between Min and Max
+	// (rejection sampling). The result follows the configured distribution
+	// conditioned on the bounds, so there are no spikes at the bounds, but the
+	// mean is no longer exactly Expected. For the exponential distribution,
+	// the resampled wait times are still memoryless between the bounds.
+	Resample = Truncation("resample")
+	// Shift adds Min to wait times drawn with a mean of Expected-Min, so no
+	// wait time is shorter than Min, and the mean stays Expected when Max is
+	// unset or rarely reached. For the exponential distribution, this is a
+	// shifted exponential distribution, which is memoryless beyond Min. Wait
+	// times above Max are resampled, which lowers the mean.
+	Shift = Truncation("shift")
+)
+
+var truncations = []Truncation{Clamp, Resample, Shift}
+
+// maxResamples limits the number of draws made by Resample and Shift. If no
+// draw falls within the bounds, which only happens when they are extremely
+// narrow, the last draw is clamped.
+const maxResamples = 1000
+
+// Get is required for all flag.Flag values.
+func (t Truncation) Get() interface{} {
+	return t
+}
+
+// Set is required for all flag.Flag values.
+func (t *Truncation) Set(s string) error {
+	for _, known := range truncations {
+		if Truncation(s) == known {
+			*t = known
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown truncation %q, valid values are %q", s, truncations)
+}
+
+// String is required for all flag.Flag values.
+func (t Truncation) String() string {
+	return string(t)
+}
+
+// checkTruncation returns an error if the truncation of the config is invalid.
+func (c Config) checkTruncation() error {
+	switch c.Truncation {
+	case "", Clamp, Resample:
+		return nil
+	case Shift:
+		if err := c.shifted().checkDistribution(); err != nil {
+			return fmt.Errorf("invalid distribution after shifting by Min(%v): %w", c.Min, err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown truncation %q", c.Truncation)
+	}
+}
+
+// shifted returns the config whose samples, plus Min, are the samples of a
+// Shift truncation.
+func (c Config) shifted() Config {
+	s := c
+	s.Expected -= c.Min
+	return s
+}
+
+func (c Config) clamp(wt time.Duration) time.Duration {
+	if wt < c.Min {
+		wt = c.Min
+	}
+	if c.Max != 0 && wt > c.Max {
+		wt = c.Max
+	}
+	return wt
+}
+
+// resample calls draw until it returns a wait time between Min and Max.
+func (c Config) resample(draw func() time.Duration) time.Duration {
+	var wt time.Duration
+	for i := 0; i < maxResamples; i++ {
+		wt = draw()
+		if wt >= c.Min && (c.Max == 0 || wt <= c.Max) {
+			return wt
+		}
+	}
+	return c.clamp(wt)
+}
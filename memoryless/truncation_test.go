@@ -0,0 +1,177 @@
+package memoryless_test
+
+import (
+	"context"
+	"flag"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/m-lab/go/memoryless"
+	"github.com/m-lab/go/rtx"
+)
+
+// sampleStats returns the mean and variance of n wait times drawn from c, in
+// seconds and seconds squared, and the fraction of wait times equal to Min or
+// Max.
+func sampleStats(c memoryless.Config, n int) (mean, variance, atBounds float64) {
+	sum, sumsq, bounds := 0.0, 0.0, 0
+	for i := 0; i < n; i++ {
+		wt := c.WaitTime()
+		if wt == c.Min || wt == c.Max {
+			bounds++
+		}
+		s := wt.Seconds()
+		sum += s
+		sumsq += s * s
+	}
+	mean = sum / float64(n)
+	return mean, sumsq/float64(n) - mean*mean, float64(bounds) / float64(n)
+}
+
+// truncatedExponential returns the mean and variance of an exponential
+// distribution with mean e, conditioned on being at most t.
+func truncatedExponential(e, t float64) (mean, variance float64) {
+	x := math.Exp(t / e)
+	return e - t/(x-1), e*e - t*t*x/((x-1)*(x-1))
+}
+
+func TestTruncation(t *testing.T) {
+	resampleMean, resampleVar := truncatedExponential(1, 1.75)
+	shiftMean, shiftVar := truncatedExponential(0.75, 1.75)
+	tests := []struct {
+		name         string
+		config       memoryless.Config
+		wantMean     float64
+		wantVariance float64
+	}{
+		{
+			// An exponential distribution conditioned on being at least Min
+			// is Min plus the same exponential distribution.
+			name:         "resample-exponential-min",
+			config:       memoryless.Config{Expected: time.Second, Min: 250 * time.Millisecond, Truncation: memoryless.Resample},
+			wantMean:     1.25,
+			wantVariance: 1,
+		},
+		{
+			name:         "resample-exponential-min-max",
+			config:       memoryless.Config{Expected: time.Second, Min: 250 * time.Millisecond, Max: 2 * time.Second, Truncation: memoryless.Resample},
+			wantMean:     0.25 + resampleMean,
+			wantVariance: resampleVar,
+		},
+		{
+			name:         "resample-uniform",
+			config:       memoryless.Config{Expected: time.Second, Min: 500 * time.Millisecond, Max: time.Second, Distribution: memoryless.Uniform, Truncation: memoryless.Resample},
+			wantMean:     0.75,
+			wantVariance: 0.25 / 12,
+		},
+		{
+			name:         "shift-exponential-min",
+			config:       memoryless.Config{Expected: time.Second, Min: 250 * time.Millisecond, Truncation: memoryless.Shift},
+			wantMean:     1,
+			wantVariance: 0.75 * 0.75,
+		},
+		{
+			name:         "shift-exponential-min-max",
+			config:       memoryless.Config{Expected: time.Second, Min: 250 * time.Millisecond, Max: 2 * time.Second, Truncation: memoryless.Shift},
+			wantMean:     0.25 + shiftMean,
+			wantVariance: shiftVar,
+		},
+		{
+			name:         "shift-uniform",
+			config:       memoryless.Config{Expected: time.Second, Min: 500 * time.Millisecond, Distribution: memoryless.Uniform, Truncation: memoryless.Shift},
+			wantMean:     1,
+			wantVariance: 1.0 / 12,
+		},
+		{
+			name:         "shift-constant-jitter",
+			config:       memoryless.Config{Expected: time.Second, Min: 500 * time.Millisecond, Distribution: memoryless.ConstantJitter, Jitter: 500 * time.Millisecond, Truncation: memoryless.Shift},
+			wantMean:     1,
+			wantVariance: 1.0 / 12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rtx.Must(tt.config.Check(), "Bad config")
+			tt.config.Rand = rand.New(rand.NewSource(1))
+			mean, variance, atBounds := sampleStats(tt.config, 200000)
+			if math.Abs(mean-tt.wantMean) > 0.02*tt.wantMean {
+				t.Errorf("mean = %v, want %v", mean, tt.wantMean)
+			}
+			if math.Abs(variance-tt.wantVariance) > 0.05*tt.wantVariance {
+				t.Errorf("variance = %v, want %v", variance, tt.wantVariance)
+			}
+			// Unlike clamping, truncation puts no probability mass at the bounds.
+			if atBounds > 0.001 {
+				t.Errorf("%v of wait times were at the bounds, want none", atBounds)
+			}
+		})
+	}
+}
+
+func TestClampingPutsMassAtBounds(t *testing.T) {
+	c := memoryless.Config{
+		Expected: time.Second,
+		Min:      250 * time.Millisecond,
+		Max:      2 * time.Second,
+		Rand:     rand.New(rand.NewSource(1)),
+	}
+	// P(X < 0.25) + P(X > 2) for an exponential distribution with mean 1.
+	want := 1 - math.Exp(-0.25) + math.Exp(-2)
+	if _, _, atBounds := sampleStats(c, 200000); math.Abs(atBounds-want) > 0.01 {
+		t.Errorf("%v of wait times were at the bounds, want %v", atBounds, want)
+	}
+}
+
+func TestTruncationWithEmptyRange(t *testing.T) {
+	// No sample can ever fall between the bounds, so the wait time ends up
+	// clamped instead of resampling forever.
+	for _, tr := range []memoryless.Truncation{memoryless.Resample, memoryless.Shift} {
+		c := memoryless.Config{Expected: time.Second, Min: time.Second, Max: time.Second, Truncation: tr}
+		rtx.Must(c.Check(), "Bad config")
+		if wt := c.WaitTime(); wt != time.Second {
+			t.Errorf("%v: WaitTime() = %v, want %v", tr, wt, time.Second)
+		}
+	}
+}
+
+func TestTruncationTicker(t *testing.T) {
+	wt := time.Microsecond
+	ticker, err := memoryless.NewTicker(context.Background(), memoryless.Config{
+		Expected: wt, Min: wt / 2, Truncation: memoryless.Shift,
+	})
+	rtx.Must(err, "Could not make ticker")
+	defer ticker.Stop()
+	<-ticker.C
+}
+
+func TestBadTruncationArgs(t *testing.T) {
+	for _, c := range []memoryless.Config{
+		{Expected: time.Second, Truncation: "reflect"},
+		{Expected: time.Second, Min: 600 * time.Millisecond, Distribution: memoryless.ConstantJitter, Jitter: 500 * time.Millisecond, Truncation: memoryless.Shift},
+	} {
+		if c.Check() == nil {
+			t.Errorf("Should have had an error with config %+v", c)
+		}
+		if _, err := memoryless.NewTicker(context.Background(), c); err == nil {
+			t.Errorf("Should have had an error running config %+v", c)
+		}
+	}
+}
+
+func TestTruncationFlag(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	tr := memoryless.Clamp
+	fs.Var(&tr, "truncation", "")
+	rtx.Must(fs.Parse([]string{"-truncation=shift"}), "Could not parse flags")
+	if tr != memoryless.Shift || tr.String() != "shift" || tr.Get() != memoryless.Shift {
+		t.Errorf("Truncation flag = %v, want shift", tr)
+	}
+	for _, s := range []string{"clamp", "resample", "shift"} {
+		rtx.Must(tr.Set(s), "Could not set %q", s)
+	}
+	if tr.Set("reflect") == nil {
+		t.Error("Set() should have failed for an unknown truncation")
+	}
+}
@@ -1,14 +1,24 @@
 package siteinfo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sync"
+	"time"
 )
 
 const (
 	baseURLFormat = "https://siteinfo.%s.measurementlab.net/%s/"
+
+	// DefaultRetries is the number of retries of requests failing with a 5xx
+	// status used by clients made by New.
+	DefaultRetries = 3
+	// DefaultBackoff is the wait before the first retry used by clients made
+	// by New.
+	DefaultBackoff = time.Second
 )
 
 // HTTPProvider is a data provider returning HTTP responses.
@@ -17,11 +27,47 @@ type HTTPProvider interface {
 	Get(string) (*http.Response, error)
 }
 
+// HTTPDoer is an HTTPProvider that can also send arbitrary requests.
+// http.Client satisfies this interface. A Client whose HTTPProvider is an
+// HTTPDoer passes contexts to its requests and revalidates cached content
+// with conditional GETs. Other HTTPProviders only receive plain GETs.
+type HTTPDoer interface {
+	HTTPProvider
+	Do(*http.Request) (*http.Response, error)
+}
+
+// StatusError is returned when siteinfo responds with an unexpected HTTP
+// status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("siteinfo returned status %d (%s) for %s", e.StatusCode, http.StatusText(e.StatusCode), e.URL)
+}
+
 // Client is a Siteinfo client.
 type Client struct {
-	ProjectID  string
-	Version    string
+	ProjectID string
+	Version   string
+
+	// TTL is how long fetched content is used without asking the server
+	// whether it changed. If it is zero, every call asks the server, but
+	// content that did not change (according to its ETag or Last-Modified
+	// headers) is not downloaded again.
+	TTL time.Duration
+	// Retries is the number of times a request failing with a 5xx status is
+	// retried.
+	Retries int
+	// Backoff is the wait before the first retry. It doubles with every
+	// subsequent retry.
+	Backoff time.Duration
+
 	httpClient HTTPProvider
+	cache      *cache
+	// files, if non-nil, replaces httpClient for clients made by NewFromURL.
+	files *fileSource
 }
 
 // New returns a new Siteinfo client wrapping the provided *http.Client.
@@ -30,34 +76,147 @@ func New(projectID, version string, httpClient HTTPProvider) *Client {
 		ProjectID:  projectID,
 		httpClient: httpClient,
 		Version:    version,
+		Retries:    DefaultRetries,
+		Backoff:    DefaultBackoff,
+		cache:      &cache{entries: map[string]*cacheEntry{}},
 	}
 }
 
-func (c Client) getContent(url string) ([]byte, error) {
-	resp, err := c.httpClient.Get(url)
-	if err != nil {
-		return nil, err
+// cacheEntry is the content fetched from a URL.
+type cacheEntry struct {
+	body         []byte
+	etag         string
+	lastModified string
+	fetched      time.Time
+}
+
+// cache holds the latest content fetched from every URL. It is shared by all
+// copies of a Client.
+type cache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+func (c *cache) get(url string) *cacheEntry {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[url]
+}
+
+func (c *cache) put(url string, e *cacheEntry) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = e
+}
+
+// fetch makes a single request for the url, conditional on the cached entry
+// if there is one. It returns the new entry, which is the cached one if the
+// content did not change.
+func (c Client) fetch(ctx context.Context, url string, cached *cacheEntry) (*cacheEntry, error) {
+	var resp *http.Response
+	var err error
+	if doer, ok := c.httpClient.(HTTPDoer); ok {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil && cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached != nil && cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+		resp, err = doer.Do(req)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		resp, err = c.httpClient.Get(url)
+		if err != nil {
+			return nil, err
+		}
 	}
 	defer resp.Body.Close()
 
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		return &cacheEntry{
+			body:         cached.body,
+			etag:         cached.etag,
+			lastModified: cached.lastModified,
+			fetched:      time.Now(),
+		}, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+	}
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
-	return body, nil
+	return &cacheEntry{
+		body:         body,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		fetched:      time.Now(),
+	}, nil
+}
+
+func (c Client) getContent(ctx context.Context, url string) ([]byte, error) {
+	cached := c.cache.get(url)
+	if cached != nil && time.Since(cached.fetched) < c.TTL {
+		return cached.body, nil
+	}
+	if c.files != nil {
+		return c.files.get(ctx, url, c.cache)
+	}
+	backoff := c.Backoff
+	for retry := 0; ; retry++ {
+		e, err := c.fetch(ctx, url, cached)
+		if err == nil {
+			c.cache.put(url, e)
+			return e.body, nil
+		}
+		serr, ok := err.(*StatusError)
+		if !ok || serr.StatusCode < 500 || retry >= c.Retries {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// getJSON fetches the named siteinfo file and decodes it into v.
+func (c Client) getJSON(ctx context.Context, file string, v interface{}) error {
+	body, err := c.getContent(ctx, c.makeBaseURL()+file)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
 }
 
 // Switches fetches the sites/switches.json output format and returns its
 // content as a map[site]Switch.
 func (c Client) Switches() (map[string]Switch, error) {
-	url := c.makeBaseURL() + "sites/switches.json"
-	body, err := c.getContent(url)
-	if err != nil {
-		return nil, err
-	}
+	return c.SwitchesContext(context.Background())
+}
+
+// SwitchesContext is like Switches, with a context for the request.
+func (c Client) SwitchesContext(ctx context.Context) (map[string]Switch, error) {
 	res := make(map[string]Switch)
-	err = json.Unmarshal(body, &res)
-	if err != nil {
+	if err := c.getJSON(ctx, "sites/switches.json", &res); err != nil {
 		return nil, err
 	}
 	return res, nil
@@ -66,14 +225,13 @@ func (c Client) Switches() (map[string]Switch, error) {
 // Projects fetches the sites/projects.json output format and returns its
 // content as a map[<short-node-name>]string.
 func (c Client) Projects() (map[string]string, error) {
-	url := c.makeBaseURL() + "sites/projects.json"
-	body, err := c.getContent(url)
-	if err != nil {
-		return nil, err
-	}
+	return c.ProjectsContext(context.Background())
+}
+
+// ProjectsContext is like Projects, with a context for the request.
+func (c Client) ProjectsContext(ctx context.Context) (map[string]string, error) {
 	res := make(map[string]string)
-	err = json.Unmarshal(body, &res)
-	if err != nil {
+	if err := c.getJSON(ctx, "sites/projects.json", &res); err != nil {
 		return nil, err
 	}
 	return res, nil
@@ -82,14 +240,13 @@ func (c Client) Projects() (map[string]string, error) {
 // Machines fetches the sites/machines.json output format and returns its
 // content as a []Machine.
 func (c Client) Machines() ([]Machine, error) {
-	url := c.makeBaseURL() + "sites/machines.json"
-	body, err := c.getContent(url)
-	if err != nil {
-		return nil, err
-	}
+	return c.MachinesContext(context.Background())
+}
+
+// MachinesContext is like Machines, with a context for the request.
+func (c Client) MachinesContext(ctx context.Context) ([]Machine, error) {
 	res := []Machine{}
-	err = json.Unmarshal(body, &res)
-	if err != nil {
+	if err := c.getJSON(ctx, "sites/machines.json", &res); err != nil {
 		return nil, err
 	}
 	return res, nil
@@ -98,19 +255,70 @@ func (c Client) Machines() ([]Machine, error) {
 // SiteMachines fetches the sites/site-machines.json output format and returns
 // its content as a map[<short-node-name>][]string.
 func (c Client) SiteMachines() (map[string][]string, error) {
-	url := c.makeBaseURL() + "sites/site-machines.json"
-	body, err := c.getContent(url)
-	if err != nil {
+	return c.SiteMachinesContext(context.Background())
+}
+
+// SiteMachinesContext is like SiteMachines, with a context for the request.
+func (c Client) SiteMachinesContext(ctx context.Context) (map[string][]string, error) {
+	res := make(map[string][]string)
+	if err := c.getJSON(ctx, "sites/site-machines.json", &res); err != nil {
 		return nil, err
 	}
-	res := make(map[string][]string)
-	err = json.Unmarshal(body, &res)
+	return res, nil
+}
+
+// Sites fetches the sites/sites.json output format and returns its content as
+// a []Site.
+func (c Client) Sites() ([]Site, error) {
+	return c.SitesContext(context.Background())
+}
+
+// SitesContext is like Sites, with a context for the request.
+func (c Client) SitesContext(ctx context.Context) ([]Site, error) {
+	res := []Site{}
+	if err := c.getJSON(ctx, "sites/sites.json", &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Locations fetches the sites/sites.json output format and returns the
+// location of every site as a map[site]Location.
+func (c Client) Locations() (map[string]Location, error) {
+	return c.LocationsContext(context.Background())
+}
+
+// LocationsContext is like Locations, with a context for the request.
+func (c Client) LocationsContext(ctx context.Context) (map[string]Location, error) {
+	sites, err := c.SitesContext(ctx)
 	if err != nil {
 		return nil, err
 	}
+	res := make(map[string]Location, len(sites))
+	for _, s := range sites {
+		res[s.Name] = s.Location
+	}
+	return res, nil
+}
+
+// Annotations fetches the sites/annotations.json output format and returns
+// its content as a map[hostname]Annotation.
+func (c Client) Annotations() (map[string]Annotation, error) {
+	return c.AnnotationsContext(context.Background())
+}
+
+// AnnotationsContext is like Annotations, with a context for the request.
+func (c Client) AnnotationsContext(ctx context.Context) (map[string]Annotation, error) {
+	res := make(map[string]Annotation)
+	if err := c.getJSON(ctx, "sites/annotations.json", &res); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
 func (s Client) makeBaseURL() string {
+	if s.files != nil {
+		return s.files.base
+	}
 	return fmt.Sprintf(baseURLFormat, s.ProjectID, s.Version)
 }
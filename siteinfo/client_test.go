@@ -1,9 +1,17 @@
 package siteinfo
 
 import (
+	"context"
+	"errors"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/rtx"
 	"github.com/m-lab/go/siteinfo/siteinfotest"
 )
 
@@ -250,3 +258,284 @@ func TestClient_SiteMachines(t *testing.T) {
 		t.Error("SiteMachines(): expected err, got nil.")
 	}
 }
+
+// rewriteTransport sends all requests to a test server.
+type rewriteTransport struct {
+	srv *httptest.Server
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(t.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newTestClient returns a client whose requests are served by h.
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := New("test", "v2", &http.Client{Transport: &rewriteTransport{srv: srv}})
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func TestClient_ConditionalGet(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		cond   string
+	}{
+		{name: "etag", header: "ETag", value: `"v1"`, cond: "If-None-Match"},
+		{name: "last-modified", header: "Last-Modified", value: "Mon, 02 Jan 2006 15:04:05 GMT", cond: "If-Modified-Since"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downloads := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(tt.cond) == tt.value {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				downloads++
+				w.Header().Set(tt.header, tt.value)
+				io.WriteString(w, `{"mlab1-lol01": "mlab-oti"}`)
+			})
+			for i := 0; i < 3; i++ {
+				res, err := c.Projects()
+				rtx.Must(err, "Could not get projects")
+				if res["mlab1-lol01"] != "mlab-oti" {
+					t.Errorf("Projects() = %v", res)
+				}
+			}
+			if downloads != 1 {
+				t.Errorf("content was downloaded %d times, want 1", downloads)
+			}
+		})
+	}
+}
+
+func TestClient_TTL(t *testing.T) {
+	requests := 0
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		io.WriteString(w, `{"abc01": ["mlab1"]}`)
+	})
+	c.TTL = time.Hour
+	for i := 0; i < 3; i++ {
+		_, err := c.SiteMachines()
+		rtx.Must(err, "Could not get site machines")
+	}
+	// A copy of the client shares its cache.
+	copied := *c
+	_, err := copied.SiteMachines()
+	rtx.Must(err, "Could not get site machines")
+	if requests != 1 {
+		t.Errorf("server got %d requests, want 1", requests)
+	}
+
+	c.TTL = 0
+	_, err = c.SiteMachines()
+	rtx.Must(err, "Could not get site machines")
+	if requests != 2 {
+		t.Errorf("server got %d requests, want 2", requests)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		status       int
+		retries      int
+		wantErr      bool
+		wantRequests int
+	}{
+		{name: "success-after-retries", failures: 2, status: http.StatusServiceUnavailable, retries: 3, wantRequests: 3},
+		{name: "too-many-failures", failures: 5, status: http.StatusInternalServerError, retries: 1, wantErr: true, wantRequests: 2},
+		{name: "no-retry-on-4xx", failures: 5, status: http.StatusNotFound, retries: 3, wantErr: true, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tt.failures {
+					w.WriteHeader(tt.status)
+					io.WriteString(w, "not json")
+					return
+				}
+				io.WriteString(w, `[]`)
+			})
+			c.Retries = tt.retries
+			_, err := c.MachinesContext(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MachinesContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var serr *StatusError
+			if tt.wantErr && (!errors.As(err, &serr) || serr.StatusCode != tt.status) {
+				t.Errorf("MachinesContext() error = %v, want a StatusError with status %d", err, tt.status)
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestClient_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	if _, err := c.SwitchesContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("SwitchesContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	// Contexts are checked before requests by HTTPProviders without Do.
+	c = New("test", "v2", &siteinfotest.StringProvider{Response: "{}"})
+	if _, err := c.SwitchesContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("SwitchesContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	// Canceling the context stops the backoff between retries.
+	retryCtx, retryCancel := context.WithCancel(context.Background())
+	defer retryCancel()
+	c = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		retryCancel()
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	c.Backoff = time.Hour
+	if _, err := c.ProjectsContext(retryCtx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ProjectsContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	err := &StatusError{URL: "https://example.com/", StatusCode: http.StatusNotFound}
+	if err.Error() != "siteinfo returned status 404 (Not Found) for https://example.com/" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+}
+
+func TestClient_Sites(t *testing.T) {
+	prov := &siteinfotest.FileReaderProvider{
+		Path: "testdata/sites.json",
+	}
+	client := New("test", "v2", prov)
+
+	// This should return the content of the test file.
+	res, err := client.Sites()
+	if err != nil {
+		t.Fatalf("Sites() returned err: %v", err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("Sites(): wrong len %d, expected %d", len(res), 3)
+	}
+	want := Site{
+		Name: "abc09",
+		Location: Location{
+			City:          "New York",
+			State:         "NY",
+			CountryCode:   "US",
+			ContinentCode: "NA",
+			Metro:         "abc",
+			Latitude:      40.7667,
+			Longitude:     -73.8667,
+		},
+		Network: SiteNetwork{
+			IPv4: SitePrefix{Prefix: "192.168.5.128/26"},
+			IPv6: SitePrefix{Prefix: "2004:42a8:144:6::/64"},
+		},
+		Transit: Transit{
+			Provider: "TATA COMMUNICATIONS (AMERICA) INC",
+			ASN:      "AS6453",
+			Uplink:   "10g",
+		},
+	}
+	if diff := deep.Equal(res[0], want); diff != nil {
+		t.Errorf("Sites() returned unexpected site: %v", diff)
+	}
+
+	locations, err := client.Locations()
+	if err != nil {
+		t.Fatalf("Locations() returned err: %v", err)
+	}
+	if len(locations) != 3 || locations["xyz05"].City != "Sydney" {
+		t.Errorf("Locations() returned unexpected locations: %v", locations)
+	}
+
+	// Make the HTTP client fail.
+	client.httpClient = &siteinfotest.FailingProvider{}
+	if _, err = client.Sites(); err == nil {
+		t.Errorf("Sites(): expected err, got nil.")
+	}
+	if _, err = client.Locations(); err == nil {
+		t.Errorf("Locations(): expected err, got nil.")
+	}
+
+	// Make the JSON unmarshalling fail.
+	client.httpClient = &siteinfotest.StringProvider{
+		Response: "this will fail",
+	}
+	if _, err = client.Sites(); err == nil {
+		t.Errorf("Sites(): expected err, got nil.")
+	}
+}
+
+func TestClient_Annotations(t *testing.T) {
+	prov := &siteinfotest.FileReaderProvider{
+		Path: "testdata/annotations.json",
+	}
+	client := New("test", "v2", prov)
+
+	// This should return the content of the test file.
+	res, err := client.Annotations()
+	if err != nil {
+		t.Fatalf("Annotations() returned err: %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("Annotations(): wrong len %d, expected %d", len(res), 2)
+	}
+	a, ok := res["mlab2-abc09.mlab-oti.measurement-lab.org"]
+	if !ok {
+		t.Fatalf("Annotations() didn't return the expected result.")
+	}
+	if a.Annotation.Site != "abc09" || a.Annotation.Geo.Latitude != 40.7667 ||
+		a.Annotation.Network.ASNumber != 6453 || a.Annotation.Network.Systems[0].ASNs[0] != 6453 ||
+		a.Network.IPv4 != "192.168.5.128/26" || a.Type != "physical" {
+		t.Errorf("Annotations() returned unexpected annotation: %+v", a)
+	}
+
+	// Make the JSON unmarshalling fail.
+	client.httpClient = &siteinfotest.StringProvider{
+		Response: "this will fail",
+	}
+	if _, err = client.Annotations(); err == nil {
+		t.Errorf("Annotations(): expected err, got nil.")
+	}
+}
+
+func TestTransit_ASNumber(t *testing.T) {
+	for asn, want := range map[string]uint32{
+		"AS6453":     6453,
+		"as15169":    15169,
+		"4294967295": 4294967295,
+	} {
+		got, err := Transit{ASN: asn}.ASNumber()
+		if err != nil || got != want {
+			t.Errorf("ASNumber(%q) = %d, %v; want %d", asn, got, err, want)
+		}
+	}
+	for _, asn := range []string{"", "AS", "ASx", "AS4294967296"} {
+		if _, err := (Transit{ASN: asn}).ASNumber(); err == nil {
+			t.Errorf("ASNumber(%q) should fail", asn)
+		}
+	}
+}
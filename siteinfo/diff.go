@@ -0,0 +1,86 @@
+package siteinfo
+
+import "sort"
+
+// MachineChange is a machine whose fields differ between two snapshots.
+type MachineChange struct {
+	Hostname string `json:"hostname"`
+	// Fields are the JSON names of the fields that differ.
+	Fields []string `json:"fields"`
+	Old    Machine  `json:"old"`
+	New    Machine  `json:"new"`
+}
+
+// Diff is the difference between the machines of two snapshots. Every list is
+// sorted by hostname.
+type Diff struct {
+	Added   []Machine       `json:"added"`
+	Removed []Machine       `json:"removed"`
+	Changed []MachineChange `json:"changed"`
+}
+
+// Empty returns whether there is no difference.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Breaking returns whether any machine was removed or changed, which may break
+// users of the old snapshot, unlike added machines.
+func (d Diff) Breaking() bool {
+	return len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+// DiffMachines returns the difference between the old and new machines, which
+// are matched by hostname.
+func DiffMachines(old, new []Machine) Diff {
+	d := Diff{Added: []Machine{}, Removed: []Machine{}, Changed: []MachineChange{}}
+	before := map[string]Machine{}
+	for _, m := range old {
+		before[m.Hostname] = m
+	}
+	after := map[string]Machine{}
+	for _, m := range new {
+		after[m.Hostname] = m
+		o, ok := before[m.Hostname]
+		if !ok {
+			d.Added = append(d.Added, m)
+			continue
+		}
+		if fields := changedFields(o, m); len(fields) > 0 {
+			d.Changed = append(d.Changed, MachineChange{Hostname: m.Hostname, Fields: fields, Old: o, New: m})
+		}
+	}
+	for _, m := range old {
+		if _, ok := after[m.Hostname]; !ok {
+			d.Removed = append(d.Removed, m)
+		}
+	}
+	sort.Slice(d.Added, func(i, j int) bool { return d.Added[i].Hostname < d.Added[j].Hostname })
+	sort.Slice(d.Removed, func(i, j int) bool { return d.Removed[i].Hostname < d.Removed[j].Hostname })
+	sort.Slice(d.Changed, func(i, j int) bool { return d.Changed[i].Hostname < d.Changed[j].Hostname })
+	return d
+}
+
+func changedFields(a, b Machine) []string {
+	var fields []string
+	for _, f := range []struct {
+		name string
+		a, b string
+	}{
+		{"ipv4", a.IPv4, b.IPv4},
+		{"ipv6", a.IPv6, b.IPv6},
+		{"project", a.Project, b.Project},
+		{"type", a.Type, b.Type},
+	} {
+		if f.a != f.b {
+			fields = append(fields, f.name)
+		}
+	}
+	return fields
+}
+
+// Diff returns the difference between the machines of s and the newer
+// snapshot.
+func (s *Snapshot) Diff(newer *Snapshot) Diff {
+	return DiffMachines(s.machines, newer.machines)
+}
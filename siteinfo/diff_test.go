@@ -0,0 +1,42 @@
+package siteinfo
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+)
+
+func TestDiffMachines(t *testing.T) {
+	lga1 := Machine{Hostname: "mlab1-lga01.mlab-oti.measurement-lab.org", IPv4: "192.168.0.1", IPv6: "2001:db8::1", Project: "mlab-oti", Type: "physical"}
+	lga2 := Machine{Hostname: "mlab2-lga01.mlab-oti.measurement-lab.org", IPv4: "192.168.0.2", Project: "mlab-oti", Type: "physical"}
+	lga3 := Machine{Hostname: "mlab3-lga01.mlab-oti.measurement-lab.org", IPv4: "192.168.0.3", Project: "mlab-oti", Type: "physical"}
+	lga4 := Machine{Hostname: "mlab4-lga01.mlab-staging.measurement-lab.org", IPv4: "192.168.0.4", Project: "mlab-staging", Type: "physical"}
+
+	moved := lga1
+	moved.IPv4 = "192.168.1.1"
+	moved.Project = "mlab-staging"
+
+	old := NewSnapshot([]Machine{lga1, lga2, lga3}, nil)
+	new := NewSnapshot([]Machine{lga4, lga3, moved}, nil)
+	got := old.Diff(new)
+	want := Diff{
+		Added:   []Machine{lga4},
+		Removed: []Machine{lga2},
+		Changed: []MachineChange{{Hostname: lga1.Hostname, Fields: []string{"ipv4", "project"}, Old: lga1, New: moved}},
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("Diff() differ: %v", diff)
+	}
+	if got.Empty() || !got.Breaking() {
+		t.Errorf("Diff() Empty() = %v, Breaking() = %v, want false, true", got.Empty(), got.Breaking())
+	}
+
+	added := DiffMachines([]Machine{lga1}, []Machine{lga1, lga2})
+	if added.Empty() || added.Breaking() {
+		t.Errorf("DiffMachines() with additions: Empty() = %v, Breaking() = %v, want false, false", added.Empty(), added.Breaking())
+	}
+	same := DiffMachines([]Machine{lga1, lga2}, []Machine{lga2, lga1})
+	if !same.Empty() || same.Breaking() {
+		t.Errorf("DiffMachines() of the same machines = %+v, want no difference", same)
+	}
+}
@@ -1,11 +1,17 @@
 package siteinfo
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 // Structs corresponding to entities in siteinfo's output formats.
 // (https://github.com/m-lab/siteinfo/tree/master/formats/v1/sites)
 
 // Switch is an entity in /v1/sites/switches.json.
 type Switch struct {
-	AutoNegotiation string `json:"auto_negotation"`
+	AutoNegotiation string `json:"auto_negotiation"`
 	FlowControl     string `json:"flow_control"`
 	IPv4Prefix      string `json:"ipv4_prefix"`
 	RSTP            string `json:"rstp"`
@@ -15,6 +21,7 @@ type Switch struct {
 	UplinkSpeed     string `json:"uplink_speed"`
 }
 
+// Machine is an entity in /v2/sites/machines.json.
 type Machine struct {
 	Hostname string `json:"hostname"`
 	IPv4     string `json:"ipv4"`
@@ -22,3 +29,98 @@ type Machine struct {
 	Project  string `json:"project"`
 	Type     string `json:"type"`
 }
+
+// Site is an entity in /v2/sites/sites.json, describing a site and the
+// network it is connected to.
+type Site struct {
+	Name     string      `json:"name"`
+	Location Location    `json:"location"`
+	Network  SiteNetwork `json:"network"`
+	Transit  Transit     `json:"transit"`
+}
+
+// Location is the geographic location of a site.
+type Location struct {
+	City          string  `json:"city"`
+	State         string  `json:"state"`
+	CountryCode   string  `json:"country_code"`
+	ContinentCode string  `json:"continent_code"`
+	Metro         string  `json:"metro"`
+	Latitude      float64 `json:"latitude"`
+	Longitude     float64 `json:"longitude"`
+}
+
+// SiteNetwork holds the prefixes assigned to a site.
+type SiteNetwork struct {
+	IPv4 SitePrefix `json:"ipv4"`
+	IPv6 SitePrefix `json:"ipv6"`
+}
+
+// SitePrefix is a prefix assigned to a site. It is empty if the site has no
+// prefix of that IP version.
+type SitePrefix struct {
+	Prefix string `json:"prefix"`
+}
+
+// Transit describes the upstream provider of a site.
+type Transit struct {
+	Provider string `json:"provider"`
+	// ASN is the AS number of the provider, e.g. "AS6453".
+	ASN    string `json:"asn"`
+	Uplink string `json:"uplink"`
+}
+
+// ASNumber returns the numeric AS number of the provider.
+func (t Transit) ASNumber() (uint32, error) {
+	n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(t.ASN), "AS"), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ASN %q: %w", t.ASN, err)
+	}
+	return uint32(n), nil
+}
+
+// Annotation is an entity in /v2/sites/annotations.json, which maps
+// hostnames to the annotations that the uuid-annotator adds to measurements
+// of the machine.
+type Annotation struct {
+	Annotation ServerAnnotation  `json:"Annotation"`
+	Network    AnnotationNetwork `json:"Network"`
+	Type       string            `json:"Type"`
+}
+
+// ServerAnnotation holds the site, geolocation and network annotations of a
+// machine.
+type ServerAnnotation struct {
+	Site    string             `json:"Site"`
+	Machine string             `json:"Machine"`
+	Geo     *GeoAnnotation     `json:"Geo,omitempty"`
+	Network *NetworkAnnotation `json:"Network,omitempty"`
+}
+
+// GeoAnnotation is the geolocation of a machine.
+type GeoAnnotation struct {
+	ContinentCode string  `json:"ContinentCode,omitempty"`
+	CountryCode   string  `json:"CountryCode,omitempty"`
+	State         string  `json:"State,omitempty"`
+	City          string  `json:"City,omitempty"`
+	Latitude      float64 `json:"Latitude,omitempty"`
+	Longitude     float64 `json:"Longitude,omitempty"`
+}
+
+// NetworkAnnotation describes the autonomous system of a machine.
+type NetworkAnnotation struct {
+	ASNumber uint32   `json:"ASNumber,omitempty"`
+	ASName   string   `json:"ASName,omitempty"`
+	Systems  []System `json:"Systems,omitempty"`
+}
+
+// System is a set of AS numbers.
+type System struct {
+	ASNs []uint32 `json:"ASNs"`
+}
+
+// AnnotationNetwork holds the prefixes of the machine's site.
+type AnnotationNetwork struct {
+	IPv4 string `json:"IPv4"`
+	IPv6 string `json:"IPv6"`
+}
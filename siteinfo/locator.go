@@ -0,0 +1,244 @@
+package siteinfo
+
+import (
+	"math"
+	"sort"
+
+	"github.com/m-lab/go/host"
+	"github.com/m-lab/go/mathx"
+)
+
+// Filter selects the machines considered by a Locator. The zero Filter
+// selects all machines.
+type Filter struct {
+	// Projects, if non-empty, selects machines in one of these projects.
+	Projects []string
+	// Types, if non-empty, selects machines of one of these types, e.g.
+	// "physical" or "virtual".
+	Types []string
+	// Healthy, if non-nil, selects machines for which it returns true.
+	Healthy func(Machine) bool
+}
+
+func (f Filter) match(m Machine) bool {
+	return (len(f.Projects) == 0 || contains(f.Projects, m.Project)) &&
+		(len(f.Types) == 0 || contains(f.Types, m.Type)) &&
+		(f.Healthy == nil || f.Healthy(m))
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Nearby is a site found by a Locator, with its selected machines and its
+// distance in km.
+type Nearby struct {
+	Site     Site
+	Machines []Machine
+	Distance float64
+}
+
+// Locator finds the sites and machines nearest to a location. Sites are kept
+// in a k-d tree of points on the unit sphere, so lookups do not scan all
+// sites. A Locator is immutable and safe for concurrent use.
+type Locator struct {
+	nodes []locatorNode
+	root  int
+}
+
+type locatorNode struct {
+	site        Site
+	machines    []Machine
+	point       [3]float64
+	axis        int
+	left, right int // Indices of the children, or -1.
+}
+
+// NewLocator returns a Locator of the given sites and machines. Machines are
+// assigned to sites by parsing their hostnames, and machines whose site is
+// not in sites are ignored.
+func NewLocator(sites []Site, machines []Machine) *Locator {
+	bySite := map[string][]Machine{}
+	for _, m := range machines {
+		if n, err := host.Parse(m.Hostname); err == nil {
+			bySite[n.Site] = append(bySite[n.Site], m)
+		}
+	}
+	l := &Locator{nodes: make([]locatorNode, len(sites))}
+	idx := make([]int, len(sites))
+	for i, s := range sites {
+		ms := bySite[s.Name]
+		sort.Slice(ms, func(i, j int) bool { return ms[i].Hostname < ms[j].Hostname })
+		l.nodes[i] = locatorNode{
+			site:     s,
+			machines: ms,
+			point:    toPoint(s.Location.Latitude, s.Location.Longitude),
+		}
+		idx[i] = i
+	}
+	l.root = l.build(idx, 0)
+	return l
+}
+
+// toPoint returns the point on the unit sphere at the given coordinates. The
+// straight-line distance between two such points increases with their
+// great-circle distance, so the nearest points are the nearest locations.
+func toPoint(lat, lon float64) [3]float64 {
+	phi := lat * math.Pi / 180
+	lambda := lon * math.Pi / 180
+	return [3]float64{
+		math.Cos(phi) * math.Cos(lambda),
+		math.Cos(phi) * math.Sin(lambda),
+		math.Sin(phi),
+	}
+}
+
+func distance2(a, b [3]float64) float64 {
+	d0, d1, d2 := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return d0*d0 + d1*d1 + d2*d2
+}
+
+// build makes a subtree of the given nodes, splitting on the median of the
+// axis, and returns the index of its root.
+func (l *Locator) build(idx []int, depth int) int {
+	if len(idx) == 0 {
+		return -1
+	}
+	axis := depth % 3
+	sort.Slice(idx, func(i, j int) bool {
+		return l.nodes[idx[i]].point[axis] < l.nodes[idx[j]].point[axis]
+	})
+	mid := len(idx) / 2
+	n := idx[mid]
+	l.nodes[n].axis = axis
+	l.nodes[n].left = l.build(idx[:mid], depth+1)
+	l.nodes[n].right = l.build(idx[mid+1:], depth+1)
+	return n
+}
+
+// candidate is a site with selected machines found during a search.
+type candidate struct {
+	node     int
+	machines []Machine
+	dist2    float64
+}
+
+// search holds the nearest candidates found so far, sorted by distance,
+// keeping just enough of them to have the wanted number of results.
+type search struct {
+	l          *Locator
+	target     [3]float64
+	filter     Filter
+	want       int
+	perMachine bool
+	found      []candidate
+}
+
+// count returns how many results the candidate is worth.
+func (s *search) count(c candidate) int {
+	if s.perMachine {
+		return len(c.machines)
+	}
+	return 1
+}
+
+// bound returns the squared distance beyond which no candidate can be part of
+// the results, which is infinite until enough results are found.
+func (s *search) bound() float64 {
+	total := 0
+	for _, c := range s.found {
+		total += s.count(c)
+		if total >= s.want {
+			return c.dist2
+		}
+	}
+	return math.Inf(1)
+}
+
+func (s *search) add(c candidate) {
+	i := sort.Search(len(s.found), func(i int) bool { return s.found[i].dist2 > c.dist2 })
+	s.found = append(s.found, candidate{})
+	copy(s.found[i+1:], s.found[i:])
+	s.found[i] = c
+	// Drop the candidates that are no longer needed.
+	total := 0
+	for i, c := range s.found {
+		total += s.count(c)
+		if total >= s.want {
+			s.found = s.found[:i+1]
+			return
+		}
+	}
+}
+
+func (s *search) visit(n int) {
+	if n < 0 {
+		return
+	}
+	node := &s.l.nodes[n]
+	d2 := distance2(node.point, s.target)
+	if d2 <= s.bound() {
+		c := candidate{node: n, dist2: d2}
+		for _, m := range node.machines {
+			if s.filter.match(m) {
+				c.machines = append(c.machines, m)
+			}
+		}
+		if len(c.machines) > 0 {
+			s.add(c)
+		}
+	}
+	diff := s.target[node.axis] - node.point[node.axis]
+	near, far := node.left, node.right
+	if diff > 0 {
+		near, far = far, near
+	}
+	s.visit(near)
+	if diff*diff <= s.bound() {
+		s.visit(far)
+	}
+}
+
+func (l *Locator) nearest(lat, lon float64, n int, f Filter, perMachine bool) []Nearby {
+	if n <= 0 {
+		return nil
+	}
+	s := &search{l: l, target: toPoint(lat, lon), filter: f, want: n, perMachine: perMachine}
+	s.visit(l.root)
+	res := make([]Nearby, 0, len(s.found))
+	for _, c := range s.found {
+		site := l.nodes[c.node].site
+		res = append(res, Nearby{
+			Site:     site,
+			Machines: c.machines,
+			Distance: mathx.GetHaversineDistance(lat, lon, site.Location.Latitude, site.Location.Longitude),
+		})
+	}
+	return res
+}
+
+// NearestSites returns up to n sites nearest to the given latitude and
+// longitude, sorted by distance. Only sites with at least one machine
+// selected by the filter are returned, along with their selected machines.
+func (l *Locator) NearestSites(lat, lon float64, n int, f Filter) []Nearby {
+	return l.nearest(lat, lon, n, f, false)
+}
+
+// NearestMachines returns up to n machines selected by the filter, from the
+// sites nearest to the given latitude and longitude, sorted by the distance
+// of their site. Machines at the same site are sorted by hostname.
+func (l *Locator) NearestMachines(lat, lon float64, n int, f Filter) []Machine {
+	var machines []Machine
+	for _, s := range l.nearest(lat, lon, n, f, true) {
+		machines = append(machines, s.Machines...)
+	}
+	if len(machines) > n {
+		machines = machines[:n]
+	}
+	return machines
+}
@@ -0,0 +1,223 @@
+package siteinfo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/mathx"
+)
+
+func locatorSites() []Site {
+	return []Site{
+		{Name: "lga01", Location: Location{City: "New York", Latitude: 40.7667, Longitude: -73.8667}},
+		{Name: "syd02", Location: Location{City: "Sydney", Latitude: -33.9461, Longitude: 151.177}},
+		{Name: "mnl01", Location: Location{City: "Manila", Latitude: 14.5086, Longitude: 121.0194}},
+		{Name: "sea03", Location: Location{City: "Seattle", Latitude: 47.4489, Longitude: -122.3094}},
+		{Name: "den05", Location: Location{City: "Denver", Latitude: 39.8561, Longitude: -104.6737}},
+	}
+}
+
+func locatorMachines() []Machine {
+	return []Machine{
+		{Hostname: "mlab2-lga01.mlab-oti.measurement-lab.org", Project: "mlab-oti", Type: "physical"},
+		{Hostname: "mlab1-lga01.mlab-oti.measurement-lab.org", Project: "mlab-oti", Type: "physical"},
+		{Hostname: "mlab4-lga01.mlab-staging.measurement-lab.org", Project: "mlab-staging", Type: "physical"},
+		{Hostname: "mlab1-syd02.mlab-oti.measurement-lab.org", Project: "mlab-oti", Type: "physical"},
+		{Hostname: "mlab1-mnl01.mlab-oti.measurement-lab.org", Project: "mlab-oti", Type: "physical"},
+		{Hostname: "mlab1-sea03.mlab-oti.measurement-lab.org", Project: "mlab-oti", Type: "virtual"},
+		{Hostname: "mlab1-den05.mlab-oti.measurement-lab.org", Project: "mlab-oti", Type: "physical"},
+		// Machines at unknown sites, or with bad names, are ignored.
+		{Hostname: "mlab1-abc01.mlab-oti.measurement-lab.org", Project: "mlab-oti", Type: "physical"},
+		{Hostname: "not-a-machine", Project: "mlab-oti", Type: "physical"},
+	}
+}
+
+func siteNames(nearby []Nearby) []string {
+	names := []string{}
+	for _, n := range nearby {
+		names = append(names, n.Site.Name)
+	}
+	return names
+}
+
+func hostnames(machines []Machine) []string {
+	names := []string{}
+	for _, m := range machines {
+		names = append(names, m.Hostname)
+	}
+	return names
+}
+
+func TestLocator_NearestSites(t *testing.T) {
+	l := NewLocator(locatorSites(), locatorMachines())
+	// Chicago.
+	lat, lon := 41.8781, -87.6298
+	tests := []struct {
+		name   string
+		n      int
+		filter Filter
+		want   []string
+	}{
+		{
+			name: "all",
+			n:    10,
+			want: []string{"lga01", "den05", "sea03", "mnl01", "syd02"},
+		},
+		{
+			name: "nearest",
+			n:    2,
+			want: []string{"lga01", "den05"},
+		},
+		{
+			name: "none",
+			n:    0,
+			want: []string{},
+		},
+		{
+			name:   "project",
+			n:      10,
+			filter: Filter{Projects: []string{"mlab-staging"}},
+			want:   []string{"lga01"},
+		},
+		{
+			name:   "type",
+			n:      2,
+			filter: Filter{Types: []string{"virtual"}},
+			want:   []string{"sea03"},
+		},
+		{
+			name: "healthy",
+			n:    2,
+			filter: Filter{Healthy: func(m Machine) bool {
+				return m.Hostname != "mlab1-den05.mlab-oti.measurement-lab.org"
+			}},
+			want: []string{"lga01", "sea03"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := l.NearestSites(lat, lon, tt.n, tt.filter)
+			if diff := deep.Equal(siteNames(got), tt.want); diff != nil {
+				t.Errorf("NearestSites() sites differ: %v", diff)
+			}
+			for i, n := range got {
+				want := mathx.GetHaversineDistance(lat, lon, n.Site.Location.Latitude, n.Site.Location.Longitude)
+				if n.Distance != want {
+					t.Errorf("NearestSites()[%d].Distance = %v, want %v", i, n.Distance, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLocator_NearestMachines(t *testing.T) {
+	l := NewLocator(locatorSites(), locatorMachines())
+	// Chicago.
+	lat, lon := 41.8781, -87.6298
+	tests := []struct {
+		name   string
+		n      int
+		filter Filter
+		want   []string
+	}{
+		{
+			name: "within-site",
+			n:    2,
+			want: []string{
+				"mlab1-lga01.mlab-oti.measurement-lab.org",
+				"mlab2-lga01.mlab-oti.measurement-lab.org",
+			},
+		},
+		{
+			name: "across-sites",
+			n:    4,
+			want: []string{
+				"mlab1-lga01.mlab-oti.measurement-lab.org",
+				"mlab2-lga01.mlab-oti.measurement-lab.org",
+				"mlab4-lga01.mlab-staging.measurement-lab.org",
+				"mlab1-den05.mlab-oti.measurement-lab.org",
+			},
+		},
+		{
+			name:   "filtered",
+			n:      3,
+			filter: Filter{Projects: []string{"mlab-oti"}, Types: []string{"physical"}},
+			want: []string{
+				"mlab1-lga01.mlab-oti.measurement-lab.org",
+				"mlab2-lga01.mlab-oti.measurement-lab.org",
+				"mlab1-den05.mlab-oti.measurement-lab.org",
+			},
+		},
+		{
+			name:   "no-match",
+			n:      3,
+			filter: Filter{Projects: []string{"mlab-sandbox"}},
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := l.NearestMachines(lat, lon, tt.n, tt.filter)
+			if diff := deep.Equal(hostnames(got), tt.want); diff != nil {
+				t.Errorf("NearestMachines() differ: %v", diff)
+			}
+		})
+	}
+}
+
+// TestLocator_MatchesLinearScan checks the k-d tree search against sorting
+// every site by distance.
+func TestLocator_MatchesLinearScan(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var sites []Site
+	var machines []Machine
+	virtual := map[string]bool{}
+	for i := 0; i < 500; i++ {
+		name := fmt.Sprintf("ab%c%02d", 'a'+i/100, i%100)
+		sites = append(sites, Site{Name: name, Location: Location{
+			Latitude:  math.Asin(2*r.Float64()-1) * 180 / math.Pi,
+			Longitude: 360*r.Float64() - 180,
+		}})
+		for j := 1; j <= 1+r.Intn(3); j++ {
+			m := Machine{
+				Hostname: fmt.Sprintf("mlab%d-%s.mlab-oti.measurement-lab.org", j, name),
+				Project:  "mlab-oti",
+				Type:     []string{"physical", "virtual"}[r.Intn(2)],
+			}
+			machines = append(machines, m)
+			virtual[name] = virtual[name] || m.Type == "virtual"
+		}
+	}
+	l := NewLocator(sites, machines)
+	f := Filter{Types: []string{"virtual"}}
+	for i := 0; i < 100; i++ {
+		lat := 180*r.Float64() - 90
+		lon := 360*r.Float64() - 180
+		var want []string
+		for _, s := range sites {
+			if virtual[s.Name] {
+				want = append(want, s.Name)
+			}
+		}
+		sort.SliceStable(want, func(i, j int) bool {
+			return distanceTo(sites, want[i], lat, lon) < distanceTo(sites, want[j], lat, lon)
+		})
+		got := siteNames(l.NearestSites(lat, lon, 10, f))
+		if diff := deep.Equal(got, want[:10]); diff != nil {
+			t.Fatalf("NearestSites(%v, %v) differ from linear scan: %v", lat, lon, diff)
+		}
+	}
+}
+
+func distanceTo(sites []Site, name string, lat, lon float64) float64 {
+	for _, s := range sites {
+		if s.Name == name {
+			return mathx.GetHaversineDistance(lat, lon, s.Location.Latitude, s.Location.Longitude)
+		}
+	}
+	return math.Inf(1)
+}
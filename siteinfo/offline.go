@@ -0,0 +1,108 @@
+package siteinfo
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-lab/go/content"
+)
+
+// fileSource reads siteinfo files through content.Providers.
+type fileSource struct {
+	base      string
+	mu        sync.Mutex
+	providers map[string]content.Provider
+}
+
+// NewFromURL returns a Client that reads the siteinfo files from the given
+// location instead of the siteinfo server, so tests and air-gapped
+// environments can use fixture data. The location is either a local directory
+// or any URL supported by content.FromURL (e.g. gs://bucket/path/), and must
+// contain the same layout as a siteinfo version directory, e.g.
+// sites/machines.json.
+//
+// Files that did not change since they were last read are not read again.
+// The TTL of the returned Client applies as usual, but its Retries and
+// Backoff are not used.
+func NewFromURL(ctx context.Context, location string) (*Client, error) {
+	base, err := baseURL(location)
+	if err != nil {
+		return nil, err
+	}
+	f := &fileSource{base: base, providers: map[string]content.Provider{}}
+	// Check that the scheme is supported before the first request.
+	f.mu.Lock()
+	_, err = f.provider(ctx, base+"sites/machines.json")
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		cache: &cache{entries: map[string]*cacheEntry{}},
+		files: f,
+	}, nil
+}
+
+// baseURL returns the URL of the location, with a trailing slash.
+func baseURL(location string) (string, error) {
+	if location == "" {
+		return "", errors.New("empty siteinfo location")
+	}
+	if !strings.Contains(location, "://") && !strings.HasPrefix(location, "file:") {
+		// A local directory.
+		abs, err := filepath.Abs(location)
+		if err != nil {
+			return "", err
+		}
+		location = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+	}
+	if !strings.HasSuffix(location, "/") {
+		location += "/"
+	}
+	return location, nil
+}
+
+// provider returns the provider of the file at the URL, creating it if needed.
+// The caller must hold f.mu.
+func (f *fileSource) provider(ctx context.Context, rawURL string) (content.Provider, error) {
+	if p, ok := f.providers[rawURL]; ok {
+		return p, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	p, err := content.FromURL(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	f.providers[rawURL] = p
+	return p, nil
+}
+
+// get reads the file at the URL, using the cached content if the file did
+// not change. Providers are not safe for concurrent use, so reads are
+// serialized.
+func (f *fileSource) get(ctx context.Context, rawURL string, c *cache) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, err := f.provider(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.Get(ctx)
+	cached := c.get(rawURL)
+	switch {
+	case err == content.ErrNoChange && cached != nil:
+		body = cached.body
+	case err != nil:
+		return nil, err
+	}
+	c.put(rawURL, &cacheEntry{body: body, fetched: time.Now()})
+	return body, nil
+}
@@ -0,0 +1,102 @@
+package siteinfo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m-lab/go/rtx"
+)
+
+// newFixtureDir returns a directory with the siteinfo layout, containing the
+// testdata files.
+func newFixtureDir(t *testing.T) string {
+	dir := t.TempDir()
+	rtx.Must(os.Mkdir(filepath.Join(dir, "sites"), 0755), "Could not make sites dir")
+	for _, name := range []string{"machines.json", "switches.json", "projects.json", "site-machines.json", "sites.json", "annotations.json"} {
+		b, err := os.ReadFile(filepath.Join("testdata", name))
+		rtx.Must(err, "Could not read %s", name)
+		rtx.Must(os.WriteFile(filepath.Join(dir, "sites", name), b, 0644), "Could not write %s", name)
+	}
+	return dir
+}
+
+func TestNewFromURL(t *testing.T) {
+	dir := newFixtureDir(t)
+	for _, location := range []string{dir, dir + "/", "file://" + dir} {
+		t.Run(location, func(t *testing.T) {
+			c, err := NewFromURL(context.Background(), location)
+			rtx.Must(err, "Could not make client")
+			machines, err := c.Machines()
+			rtx.Must(err, "Could not get machines")
+			if len(machines) != 4 {
+				t.Errorf("Machines() returned %d machines, want 4", len(machines))
+			}
+			switches, err := c.Switches()
+			rtx.Must(err, "Could not get switches")
+			if len(switches) != 144 {
+				t.Errorf("Switches() returned %d switches, want 144", len(switches))
+			}
+			// Unchanged files are served from the cache.
+			machines, err = c.Machines()
+			rtx.Must(err, "Could not get machines again")
+			if len(machines) != 4 {
+				t.Errorf("Machines() returned %d machines, want 4", len(machines))
+			}
+		})
+	}
+}
+
+func TestNewFromURL_Relative(t *testing.T) {
+	dir := newFixtureDir(t)
+	wd, err := os.Getwd()
+	rtx.Must(err, "Could not get working directory")
+	rtx.Must(os.Chdir(filepath.Dir(dir)), "Could not change directory")
+	defer os.Chdir(wd)
+
+	c, err := NewFromURL(context.Background(), filepath.Base(dir))
+	rtx.Must(err, "Could not make client")
+	rtx.Must(os.Chdir(wd), "Could not change directory")
+	// The directory is resolved when the client is made.
+	if _, err := c.Sites(); err != nil {
+		t.Errorf("Sites() returned err: %v", err)
+	}
+}
+
+func TestNewFromURL_Changes(t *testing.T) {
+	dir := newFixtureDir(t)
+	c, err := NewFromURL(context.Background(), dir)
+	rtx.Must(err, "Could not make client")
+	projects, err := c.Projects()
+	rtx.Must(err, "Could not get projects")
+	if len(projects) != 8 {
+		t.Errorf("Projects() returned %d projects, want 8", len(projects))
+	}
+
+	file := filepath.Join(dir, "sites", "projects.json")
+	rtx.Must(os.WriteFile(file, []byte(`{"mlab1-lol01": "mlab-oti"}`), 0644), "Could not write file")
+	// Make sure the modification time changes.
+	future := time.Now().Add(time.Hour)
+	rtx.Must(os.Chtimes(file, future, future), "Could not change times")
+	projects, err = c.Projects()
+	rtx.Must(err, "Could not get projects")
+	if len(projects) != 1 {
+		t.Errorf("Projects() returned %d projects, want 1", len(projects))
+	}
+
+	// Errors reading files are returned.
+	rtx.Must(os.Remove(file), "Could not remove file")
+	if _, err := c.Projects(); err == nil {
+		t.Error("Projects() should fail for a missing file")
+	}
+}
+
+func TestNewFromURL_Errors(t *testing.T) {
+	for _, location := range []string{"", "gopher://example.com/siteinfo/", "file://%zz"} {
+		if _, err := NewFromURL(context.Background(), location); err == nil {
+			t.Errorf("NewFromURL(%q) should fail", location)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package siteinfo
+
+import (
+	"context"
+	"log"
+	"net/netip"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/m-lab/go/host"
+	"github.com/m-lab/go/memoryless"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var lastRefresh = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "siteinfo_registry_last_refresh_timestamp_seconds",
+		Help: "The time of the last successful refresh of each siteinfo Registry.",
+	},
+	[]string{"project"},
+)
+
+// Source provides the siteinfo data used by a Registry. *Client implements
+// Source.
+type Source interface {
+	MachinesContext(ctx context.Context) ([]Machine, error)
+	SwitchesContext(ctx context.Context) (map[string]Switch, error)
+}
+
+// Snapshot is an indexed, immutable view of siteinfo data at one point in
+// time. All lookups are safe for concurrent use.
+type Snapshot struct {
+	// Time is when the data was fetched.
+	Time time.Time
+
+	machines []Machine
+	// byHostname maps both the hostnames from siteinfo and the canonical
+	// machine names parsed from them to machines.
+	byHostname map[string]Machine
+	bySite     map[string][]Machine
+	byAddr     map[netip.Addr]string
+	// prefixes of every site, sorted from longest to shortest.
+	prefixes []sitePrefix
+}
+
+type sitePrefix struct {
+	site   string
+	prefix netip.Prefix
+}
+
+// NewSnapshot indexes siteinfo data. Machines whose hostnames cannot be parsed
+// can only be found by their hostname, and switches without a valid prefix are
+// ignored.
+func NewSnapshot(machines []Machine, switches map[string]Switch) *Snapshot {
+	s := &Snapshot{
+		Time:       time.Now(),
+		machines:   machines,
+		byHostname: map[string]Machine{},
+		bySite:     map[string][]Machine{},
+		byAddr:     map[netip.Addr]string{},
+	}
+	for _, m := range machines {
+		s.byHostname[m.Hostname] = m
+		n, err := host.Parse(m.Hostname)
+		if err != nil {
+			continue
+		}
+		s.byHostname[machineName(n)] = m
+		s.bySite[n.Site] = append(s.bySite[n.Site], m)
+		for _, ip := range []string{m.IPv4, m.IPv6} {
+			if addr, err := netip.ParseAddr(ip); err == nil {
+				s.byAddr[addr] = n.Site
+			}
+		}
+	}
+	for site, sw := range switches {
+		if p, err := netip.ParsePrefix(sw.IPv4Prefix); err == nil {
+			s.prefixes = append(s.prefixes, sitePrefix{site: site, prefix: p.Masked()})
+		}
+	}
+	sort.Slice(s.prefixes, func(i, j int) bool {
+		if s.prefixes[i].prefix.Bits() != s.prefixes[j].prefix.Bits() {
+			return s.prefixes[i].prefix.Bits() > s.prefixes[j].prefix.Bits()
+		}
+		return s.prefixes[i].site < s.prefixes[j].site
+	})
+	return s
+}
+
+// Machines returns all machines.
+func (s *Snapshot) Machines() []Machine {
+	return s.machines
+}
+
+// Machine returns the machine with the given hostname. The hostname may also
+// be any name of a service running on the machine, e.g.
+// ndt-mlab1-lga01.mlab-oti.measurement-lab.org.
+func (s *Snapshot) Machine(hostname string) (Machine, bool) {
+	if m, ok := s.byHostname[hostname]; ok {
+		return m, true
+	}
+	n, err := host.Parse(hostname)
+	if err != nil {
+		return Machine{}, false
+	}
+	m, ok := s.byHostname[machineName(n)]
+	return m, ok
+}
+
+// machineName returns the name of the machine, without any service or suffix.
+func machineName(n host.Name) string {
+	n.Service = ""
+	return n.String()
+}
+
+// SiteMachines returns the machines at the given site.
+func (s *Snapshot) SiteMachines(site string) []Machine {
+	return s.bySite[site]
+}
+
+// SiteProjects returns the sorted projects of the machines at the given site.
+func (s *Snapshot) SiteProjects(site string) []string {
+	seen := map[string]bool{}
+	projects := []string{}
+	for _, m := range s.bySite[site] {
+		if !seen[m.Project] {
+			seen[m.Project] = true
+			projects = append(projects, m.Project)
+		}
+	}
+	sort.Strings(projects)
+	return projects
+}
+
+// SiteByAddr returns the site of the given address, which is either the
+// address of a machine or within the prefix of a site. Site prefixes come from
+// the switches data, which only has IPv4 prefixes, so IPv6 addresses are only
+// found if they are the address of a machine.
+func (s *Snapshot) SiteByAddr(addr netip.Addr) (string, bool) {
+	addr = addr.Unmap()
+	if site, ok := s.byAddr[addr]; ok {
+		return site, true
+	}
+	return s.SiteByPrefix(netip.PrefixFrom(addr, addr.BitLen()))
+}
+
+// SiteByPrefix returns the site whose prefix contains the given prefix. Only
+// the IPv4 prefixes of sites are known, so IPv6 prefixes are never found.
+func (s *Snapshot) SiteByPrefix(p netip.Prefix) (string, bool) {
+	for _, sp := range s.prefixes {
+		if p.Bits() >= sp.prefix.Bits() && sp.prefix.Contains(p.Addr()) {
+			return sp.site, true
+		}
+	}
+	return "", false
+}
+
+// Registry holds the latest Snapshot of siteinfo data, and refreshes it in
+// the background. Refreshing replaces the whole snapshot atomically, so
+// lookups never see partially refreshed data. Registry is safe for
+// concurrent use.
+type Registry struct {
+	source   Source
+	project  string
+	snapshot atomic.Pointer[Snapshot]
+}
+
+// NewRegistry returns a Registry of the data from source. The project labels
+// the metrics of the Registry. The Registry is empty until it is refreshed.
+func NewRegistry(source Source, project string) *Registry {
+	return &Registry{source: source, project: project}
+}
+
+// Refresh fetches the data and replaces the snapshot. If fetching fails, the
+// previous snapshot is kept.
+func (r *Registry) Refresh(ctx context.Context) error {
+	machines, err := r.source.MachinesContext(ctx)
+	if err != nil {
+		return err
+	}
+	switches, err := r.source.SwitchesContext(ctx)
+	if err != nil {
+		return err
+	}
+	s := NewSnapshot(machines, switches)
+	r.snapshot.Store(s)
+	lastRefresh.WithLabelValues(r.project).Set(float64(s.Time.UnixNano()) / float64(time.Second))
+	return nil
+}
+
+// RefreshEvery refreshes the registry on a memoryless schedule until the
+// context is canceled or the policy gives up. Refresh errors are logged, and
+// the previous snapshot is kept. The first refresh only happens after a random
+// wait, so callers that need data right away should call Refresh first.
+func (r *Registry) RefreshEvery(ctx context.Context, c memoryless.Config, p memoryless.Policy) error {
+	return memoryless.RunE(ctx, func(ctx context.Context) error {
+		err := r.Refresh(ctx)
+		if err != nil {
+			log.Println("Could not refresh siteinfo:", err)
+		}
+		return err
+	}, c, p)
+}
+
+// Snapshot returns the current snapshot. Callers making several lookups that
+// must be consistent with each other should use a single snapshot. Before the
+// first successful refresh, it returns an empty snapshot.
+func (r *Registry) Snapshot() *Snapshot {
+	if s := r.snapshot.Load(); s != nil {
+		return s
+	}
+	return emptySnapshot
+}
+
+var emptySnapshot = NewSnapshot(nil, nil)
+
+// Machine returns the machine with the given hostname in the current
+// snapshot.
+func (r *Registry) Machine(hostname string) (Machine, bool) {
+	return r.Snapshot().Machine(hostname)
+}
+
+// SiteMachines returns the machines at the given site in the current
+// snapshot.
+func (r *Registry) SiteMachines(site string) []Machine {
+	return r.Snapshot().SiteMachines(site)
+}
+
+// SiteProjects returns the projects of the given site in the current
+// snapshot.
+func (r *Registry) SiteProjects(site string) []string {
+	return r.Snapshot().SiteProjects(site)
+}
+
+// SiteByAddr returns the site of the given address in the current snapshot.
+// As for Snapshot.SiteByAddr, IPv6 addresses are only found if they are the
+// address of a machine.
+func (r *Registry) SiteByAddr(addr netip.Addr) (string, bool) {
+	return r.Snapshot().SiteByAddr(addr)
+}
+
+// SiteByPrefix returns the site containing the given prefix in the current
+// snapshot. As for Snapshot.SiteByPrefix, IPv6 prefixes are never found.
+func (r *Registry) SiteByPrefix(p netip.Prefix) (string, bool) {
+	return r.Snapshot().SiteByPrefix(p)
+}
@@ -0,0 +1,175 @@
+package siteinfo
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/memoryless"
+	"github.com/m-lab/go/prometheusx/promtest"
+	"github.com/m-lab/go/rtx"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeSource returns fixed data, or an error.
+type fakeSource struct {
+	machines  []Machine
+	switches  map[string]Switch
+	err       error
+	switchErr error
+}
+
+func (f *fakeSource) MachinesContext(ctx context.Context) ([]Machine, error) {
+	return f.machines, f.err
+}
+
+func (f *fakeSource) SwitchesContext(ctx context.Context) (map[string]Switch, error) {
+	return f.switches, f.switchErr
+}
+
+var (
+	lga01mlab1 = Machine{Hostname: "mlab1-lga01.mlab-oti.measurement-lab.org", IPv4: "192.0.2.1", IPv6: "2001:db8::1", Project: "mlab-oti", Type: "physical"}
+	lga01mlab4 = Machine{Hostname: "mlab4-lga01.mlab-staging.measurement-lab.org", IPv4: "192.0.2.4", Project: "mlab-staging", Type: "physical"}
+	lga3356    = Machine{Hostname: "lga3356-c0a80001.mlab.autojoin.measurement-lab.org", IPv4: "198.51.100.7", Project: "mlab-autojoin", Type: "virtual"}
+	unparsable = Machine{Hostname: "oddball.example.com", IPv4: "203.0.113.1", Project: "mlab-oti"}
+)
+
+func newTestSource() *fakeSource {
+	return &fakeSource{
+		machines: []Machine{lga01mlab1, lga01mlab4, lga3356, unparsable},
+		switches: map[string]Switch{
+			"lga01": {IPv4Prefix: "192.0.2.0/26"},
+			"lga02": {IPv4Prefix: "192.0.2.0/24"},
+			"bad01": {IPv4Prefix: "not a prefix"},
+		},
+	}
+}
+
+func TestRegistry_Lookups(t *testing.T) {
+	r := NewRegistry(newTestSource(), "test")
+	rtx.Must(r.Refresh(context.Background()), "Could not refresh")
+
+	for hostname, want := range map[string]Machine{
+		"mlab1-lga01.mlab-oti.measurement-lab.org":               lga01mlab1,
+		"ndt-mlab1-lga01.mlab-oti.measurement-lab.org":           lga01mlab1,
+		"ndt-mlab4-lga01.mlab-staging.measurement-lab.org-abcd":  lga01mlab4,
+		"ndt-lga3356-c0a80001.mlab.autojoin.measurement-lab.org": lga3356,
+		"oddball.example.com":                                    unparsable,
+	} {
+		if got, ok := r.Machine(hostname); !ok || got != want {
+			t.Errorf("Machine(%q) = %v, %v; want %v", hostname, got, ok, want)
+		}
+	}
+	for _, hostname := range []string{"mlab2-lga01.mlab-oti.measurement-lab.org", "not a hostname"} {
+		if got, ok := r.Machine(hostname); ok {
+			t.Errorf("Machine(%q) = %v, want nothing", hostname, got)
+		}
+	}
+
+	if diff := deep.Equal(r.SiteMachines("lga01"), []Machine{lga01mlab1, lga01mlab4}); diff != nil {
+		t.Errorf("SiteMachines(lga01) differs: %v", diff)
+	}
+	if got := r.SiteMachines("xyz01"); len(got) != 0 {
+		t.Errorf("SiteMachines(xyz01) = %v, want none", got)
+	}
+	if diff := deep.Equal(r.SiteProjects("lga01"), []string{"mlab-oti", "mlab-staging"}); diff != nil {
+		t.Errorf("SiteProjects(lga01) differs: %v", diff)
+	}
+	if diff := deep.Equal(r.SiteProjects("xyz01"), []string{}); diff != nil {
+		t.Errorf("SiteProjects(xyz01) differs: %v", diff)
+	}
+
+	for addr, want := range map[string]string{
+		"192.0.2.1":        "lga01", // A machine.
+		"2001:db8::1":      "lga01", // A machine's IPv6 address.
+		"::ffff:192.0.2.1": "lga01", // A mapped IPv4 address.
+		"192.0.2.30":       "lga01", // The longest matching prefix.
+		"192.0.2.200":      "lga02", // The only matching prefix.
+		"198.51.100.7":     "lga3356",
+		"203.0.113.1":      "", // Machines with unparsable names have no site.
+		"2001:db8::2":      "",
+	} {
+		got, ok := r.SiteByAddr(netip.MustParseAddr(addr))
+		if got != want || ok != (want != "") {
+			t.Errorf("SiteByAddr(%s) = %q, %v; want %q", addr, got, ok, want)
+		}
+	}
+	for prefix, want := range map[string]string{
+		"192.0.2.0/28": "lga01",
+		"192.0.2.0/25": "lga02",
+		"192.0.2.0/24": "lga02",
+		"192.0.0.0/16": "",
+	} {
+		got, ok := r.SiteByPrefix(netip.MustParsePrefix(prefix))
+		if got != want || ok != (want != "") {
+			t.Errorf("SiteByPrefix(%s) = %q, %v; want %q", prefix, got, ok, want)
+		}
+	}
+}
+
+func TestRegistry_Refresh(t *testing.T) {
+	src := newTestSource()
+	r := NewRegistry(src, "test")
+	if len(r.Snapshot().Machines()) != 0 {
+		t.Error("A new registry should be empty")
+	}
+	if _, ok := r.Machine(lga01mlab1.Hostname); ok {
+		t.Error("A new registry should find nothing")
+	}
+
+	rtx.Must(r.Refresh(context.Background()), "Could not refresh")
+	before := r.Snapshot()
+	if len(before.Machines()) != 4 {
+		t.Errorf("Snapshot has %d machines, want 4", len(before.Machines()))
+	}
+	if testutil.ToFloat64(lastRefresh.WithLabelValues("test")) != float64(before.Time.UnixNano())/1e9 {
+		t.Error("The last refresh metric should be the time of the snapshot")
+	}
+
+	// Failed refreshes keep the previous snapshot.
+	src.switchErr = errors.New("switches failure for testing")
+	if r.Refresh(context.Background()) == nil {
+		t.Error("Refresh() should fail")
+	}
+	src.switchErr = nil
+	src.err = errors.New("machines failure for testing")
+	if r.Refresh(context.Background()) == nil {
+		t.Error("Refresh() should fail")
+	}
+	if r.Snapshot() != before {
+		t.Error("A failed refresh should keep the snapshot")
+	}
+
+	// Successful refreshes replace it.
+	src.err = nil
+	src.machines = []Machine{lga3356}
+	rtx.Must(r.Refresh(context.Background()), "Could not refresh")
+	if _, ok := r.Machine(lga01mlab1.Hostname); ok {
+		t.Error("Machines removed from siteinfo should be gone after a refresh")
+	}
+	// Snapshots taken before the refresh are unchanged.
+	if _, ok := before.Machine(lga01mlab1.Hostname); !ok {
+		t.Error("Old snapshots should not change")
+	}
+	promtest.LintMetrics(t)
+}
+
+func TestRegistry_RefreshEvery(t *testing.T) {
+	src := newTestSource()
+	r := NewRegistry(src, "test")
+	c := memoryless.Config{Expected: time.Millisecond, Once: true}
+	rtx.Must(r.RefreshEvery(context.Background(), c, memoryless.Policy{}), "Could not refresh")
+	if len(r.Snapshot().Machines()) != 4 {
+		t.Error("RefreshEvery() should have refreshed the registry")
+	}
+
+	src.err = errors.New("failure for testing")
+	c.Once = false
+	err := r.RefreshEvery(context.Background(), c, memoryless.Policy{MaxConsecutiveErrors: 2})
+	if !errors.Is(err, memoryless.ErrTooManyErrors) {
+		t.Errorf("RefreshEvery() = %v, want %v", err, memoryless.ErrTooManyErrors)
+	}
+}
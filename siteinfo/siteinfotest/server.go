@@ -0,0 +1,233 @@
+package siteinfotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The files served by siteinfo, relative to the version directory.
+const (
+	Switches     = "sites/switches.json"
+	Projects     = "sites/projects.json"
+	Machines     = "sites/machines.json"
+	SiteMachines = "sites/site-machines.json"
+	Sites        = "sites/sites.json"
+	Annotations  = "sites/annotations.json"
+)
+
+// Server is a fake siteinfo server. It serves the files it is given under
+// every version directory, e.g. the content set for Machines is served at
+// /v2/sites/machines.json. Every file has a version, which is sent as its
+// ETag and changes every time the file is set, so clients making conditional
+// GETs see a change exactly when a test makes one.
+//
+// The other methods control how the server misbehaves. All methods are safe
+// for concurrent use, including while requests are being served.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	files     map[string]*file
+	latency   time.Duration
+	failures  int
+	status    int
+	malformed bool
+	requests  map[string]int
+}
+
+// file is the content served for one path.
+type file struct {
+	body    []byte
+	version int
+}
+
+// NewServer starts and returns a Server serving no files. Callers should call
+// Close when done.
+func NewServer() *Server {
+	s := &Server{
+		files:    map[string]*file{},
+		requests: map[string]int{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// NewServerFromDir starts and returns a Server serving the *.json files in
+// dir, such as the siteinfo testdata directory, as the files of the same name
+// under sites/. Callers should call Close when done.
+func NewServerFromDir(dir string) (*Server, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	s := NewServer()
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		s.SetRaw("sites/"+filepath.Base(p), b)
+	}
+	return s, nil
+}
+
+// SetRaw serves body at the given path, such as Machines, and changes its
+// version. The body is served as is, so it may be malformed on purpose.
+func (s *Server) SetRaw(path string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[path]
+	if !ok {
+		f = &file{}
+		s.files[path] = f
+	}
+	f.body = body
+	f.version++
+}
+
+// SetJSON serves the JSON encoding of v, such as a []siteinfo.Machine, at the
+// given path and changes its version.
+func (s *Server) SetJSON(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.SetRaw(path, b)
+	return nil
+}
+
+// Remove stops serving the given path, which then returns 404.
+func (s *Server) Remove(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, path)
+}
+
+// Version returns the version of the file at the given path, which starts at
+// 1 and is 0 if the path is not served.
+func (s *Server) Version(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.files[path]; ok {
+		return f.version
+	}
+	return 0
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailNext makes the next n requests fail with the given status, e.g.
+// http.StatusServiceUnavailable.
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = n
+	s.status = status
+}
+
+// SetMalformed makes the server truncate every body it sends, so that it is
+// not valid JSON.
+func (s *Server) SetMalformed(malformed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.malformed = malformed
+}
+
+// Requests returns the number of requests received for the given path,
+// including failed and conditional requests.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Client returns an *http.Client sending every request to the server, whatever
+// its URL. It can be passed to siteinfo.New.
+func (s *Server) Client() *http.Client {
+	return &http.Client{Transport: &rewriteTransport{srv: s.Server}}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	// Strip the version directory.
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	path := parts[1]
+
+	s.mu.Lock()
+	s.requests[path]++
+	latency := s.latency
+	fail := s.failures > 0
+	if fail {
+		s.failures--
+	}
+	status := s.status
+	malformed := s.malformed
+	var body []byte
+	version := 0
+	if f, ok := s.files[path]; ok {
+		body, version = f.body, f.version
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	switch {
+	case fail:
+		http.Error(w, http.StatusText(status), status)
+		return
+	case version == 0:
+		http.NotFound(w, r)
+		return
+	}
+	etag := fmt.Sprintf(`"%d"`, version)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if malformed {
+		// Without an ETag, clients do not revalidate the malformed body later.
+		body = body[:len(body)/2]
+	} else {
+		w.Header().Set("ETag", etag)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// rewriteTransport sends all requests to a test server.
+type rewriteTransport struct {
+	srv *httptest.Server
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(t.srv.URL)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	return t.srv.Client().Transport.RoundTrip(req)
+}
@@ -0,0 +1,156 @@
+package siteinfotest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/rtx"
+	"github.com/m-lab/go/siteinfo"
+)
+
+func newClient(s *Server) *siteinfo.Client {
+	c := siteinfo.New("mlab-oti", "v2", s.Client())
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func TestNewServerFromDir(t *testing.T) {
+	s, err := NewServerFromDir("../testdata")
+	rtx.Must(err, "Could not start server")
+	defer s.Close()
+	c := newClient(s)
+
+	machines, err := c.Machines()
+	rtx.Must(err, "Could not get machines")
+	if len(machines) == 0 {
+		t.Error("Machines() returned no machines")
+	}
+	sites, err := c.Sites()
+	rtx.Must(err, "Could not get sites")
+	if len(sites) == 0 {
+		t.Error("Sites() returned no sites")
+	}
+	for _, p := range []string{Switches, Projects, Machines, SiteMachines, Sites, Annotations} {
+		if s.Version(p) != 1 {
+			t.Errorf("Version(%q) = %d, want 1", p, s.Version(p))
+		}
+	}
+
+	if _, err := NewServerFromDir("[bad"); err == nil {
+		t.Error("NewServerFromDir() should fail with a bad pattern")
+	}
+}
+
+func TestServer_Versions(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := newClient(s)
+
+	v1 := []siteinfo.Machine{{Hostname: "mlab1-lga01.mlab-oti.measurement-lab.org", Project: "mlab-oti"}}
+	rtx.Must(s.SetJSON(Machines, v1), "Could not set machines")
+	got, err := c.Machines()
+	rtx.Must(err, "Could not get machines")
+	if diff := deep.Equal(got, v1); diff != nil {
+		t.Errorf("Machines() differ: %v", diff)
+	}
+
+	// Unchanged content is revalidated, not downloaded again.
+	got, err = c.Machines()
+	rtx.Must(err, "Could not get machines")
+	if diff := deep.Equal(got, v1); diff != nil {
+		t.Errorf("Machines() differ: %v", diff)
+	}
+	if s.Requests(Machines) != 2 {
+		t.Errorf("Requests() = %d, want 2", s.Requests(Machines))
+	}
+
+	v2 := append(v1, siteinfo.Machine{Hostname: "mlab2-lga01.mlab-oti.measurement-lab.org", Project: "mlab-oti"})
+	rtx.Must(s.SetJSON(Machines, v2), "Could not set machines")
+	if s.Version(Machines) != 2 {
+		t.Errorf("Version() = %d, want 2", s.Version(Machines))
+	}
+	got, err = c.Machines()
+	rtx.Must(err, "Could not get machines")
+	if diff := deep.Equal(got, v2); diff != nil {
+		t.Errorf("Machines() differ after a version change: %v", diff)
+	}
+
+	if s.SetJSON(Machines, make(chan int)) == nil {
+		t.Error("SetJSON() should fail for values that cannot be encoded")
+	}
+	s.Remove(Machines)
+	if s.Version(Machines) != 0 {
+		t.Errorf("Version() = %d after Remove(), want 0", s.Version(Machines))
+	}
+	_, err = newClient(s).Machines()
+	var se *siteinfo.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusNotFound {
+		t.Errorf("Machines() = %v, want a 404 StatusError", err)
+	}
+}
+
+func TestServer_Failures(t *testing.T) {
+	s, err := NewServerFromDir("../testdata")
+	rtx.Must(err, "Could not start server")
+	defer s.Close()
+	c := newClient(s)
+	c.Retries = 2
+
+	// Failures up to the number of retries are hidden by the client.
+	s.FailNext(2, http.StatusServiceUnavailable)
+	_, err = c.Projects()
+	rtx.Must(err, "Could not get projects after retries")
+	if s.Requests(Projects) != 3 {
+		t.Errorf("Requests() = %d, want 3", s.Requests(Projects))
+	}
+
+	s.FailNext(3, http.StatusInternalServerError)
+	_, err = c.Switches()
+	var se *siteinfo.StatusError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Switches() = %v, want a 500 StatusError", err)
+	}
+
+	s.SetMalformed(true)
+	if _, err := c.Sites(); err == nil {
+		t.Error("Sites() should fail with malformed JSON")
+	}
+	s.SetMalformed(false)
+	if _, err := c.Sites(); err != nil {
+		t.Errorf("Sites() = %v after malformed JSON is turned off", err)
+	}
+
+	// Requests outside a version directory are not found.
+	resp, err := http.Get(s.URL + "/machines.json")
+	rtx.Must(err, "Could not get %s", s.URL)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Get() status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestServer_Latency(t *testing.T) {
+	s, err := NewServerFromDir("../testdata")
+	rtx.Must(err, "Could not start server")
+	defer s.Close()
+	c := newClient(s)
+
+	s.SetLatency(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.MachinesContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("MachinesContext() = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	s.SetLatency(10 * time.Millisecond)
+	start := time.Now()
+	_, err = c.Machines()
+	rtx.Must(err, "Could not get machines")
+	if time.Since(start) < 10*time.Millisecond {
+		t.Errorf("Machines() took %v, want at least 10ms", time.Since(start))
+	}
+}
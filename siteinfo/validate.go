@@ -0,0 +1,187 @@
+package siteinfo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+
+	"github.com/m-lab/go/host"
+)
+
+// Problem is an invalid value found in siteinfo data.
+type Problem struct {
+	// File is the siteinfo file, e.g. sites/machines.json.
+	File string `json:"file"`
+	// Entity is the hostname of the machine or the name of the site.
+	Entity string `json:"entity"`
+	// Field is the JSON name of the invalid field.
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s: %s", p.File, p.Entity, p.Field, p.Reason)
+}
+
+// decodeStrict decodes the content of the siteinfo file into v, failing if it
+// has fields that v does not have or trailing data.
+func decodeStrict(file string, b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("%s: trailing data after JSON value", file)
+	}
+	return nil
+}
+
+// projectID returns the project ID of machines with the given name, or "" if
+// the name does not say.
+func projectID(n host.Name) string {
+	if n.Version == "v3" && n.Project != "" {
+		// v3 names contain the project without its "mlab-" prefix.
+		return "mlab-" + n.Project
+	}
+	return n.Project
+}
+
+// ValidateMachines decodes the content of sites/machines.json and checks that
+// every machine has a valid and unique hostname matching its project, a type,
+// and valid and unique addresses. The IPv6 address is optional. It returns an
+// error if the content cannot be decoded as []Machine, including when it has
+// fields that Machine does not have; problems with individual machines are
+// returned sorted by hostname and field.
+func ValidateMachines(b []byte) ([]Machine, []Problem, error) {
+	const file = "sites/machines.json"
+	var machines []Machine
+	if err := decodeStrict(file, b, &machines); err != nil {
+		return nil, nil, err
+	}
+	var problems []Problem
+	add := func(m Machine, field, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			File: file, Entity: m.Hostname, Field: field, Reason: fmt.Sprintf(format, args...),
+		})
+	}
+	hostnames := map[string]bool{}
+	addrs := map[netip.Addr]string{}
+	for _, m := range machines {
+		if hostnames[m.Hostname] {
+			add(m, "hostname", "duplicate hostname")
+		}
+		hostnames[m.Hostname] = true
+		n, err := host.Parse(m.Hostname)
+		if err != nil {
+			add(m, "hostname", "%v", err)
+		}
+		switch {
+		case m.Project == "":
+			add(m, "project", "missing")
+		case err == nil && projectID(n) != "" && projectID(n) != m.Project:
+			add(m, "project", "%q does not match the hostname project %q", m.Project, projectID(n))
+		}
+		if m.Type == "" {
+			add(m, "type", "missing")
+		}
+		for _, ip := range []struct {
+			field, value string
+			is4          bool
+		}{{"ipv4", m.IPv4, true}, {"ipv6", m.IPv6, false}} {
+			if ip.value == "" {
+				if ip.is4 {
+					add(m, ip.field, "missing")
+				}
+				continue
+			}
+			addr, err := netip.ParseAddr(ip.value)
+			switch {
+			case err != nil:
+				add(m, ip.field, "%v", err)
+			case addr.Is4() != ip.is4:
+				add(m, ip.field, "%q is not an %s address", ip.value, ip.field)
+			case addrs[addr] != "":
+				add(m, ip.field, "%s is also used by %s", ip.value, addrs[addr])
+			default:
+				addrs[addr] = m.Hostname
+			}
+		}
+	}
+	sortProblems(problems)
+	return machines, problems, nil
+}
+
+// ValidateSwitches decodes the content of sites/switches.json and checks that
+// every site has a valid name and IPv4 prefix. It returns an error if the
+// content cannot be decoded as map[string]Switch, including when it has fields
+// that Switch does not have; problems with individual switches are returned
+// sorted by site and field.
+func ValidateSwitches(b []byte) (map[string]Switch, []Problem, error) {
+	const file = "sites/switches.json"
+	var switches map[string]Switch
+	if err := decodeStrict(file, b, &switches); err != nil {
+		return nil, nil, err
+	}
+	var problems []Problem
+	add := func(site, field, format string, args ...interface{}) {
+		problems = append(problems, Problem{
+			File: file, Entity: site, Field: field, Reason: fmt.Sprintf(format, args...),
+		})
+	}
+	for site, sw := range switches {
+		if _, err := host.ParseSite(site); err != nil {
+			add(site, "site", "%v", err)
+		}
+		p, err := netip.ParsePrefix(sw.IPv4Prefix)
+		switch {
+		case err != nil:
+			add(site, "ipv4_prefix", "%v", err)
+		case !p.Addr().Is4():
+			add(site, "ipv4_prefix", "%q is not an IPv4 prefix", sw.IPv4Prefix)
+		}
+	}
+	sortProblems(problems)
+	return switches, problems, nil
+}
+
+func sortProblems(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Entity != problems[j].Entity {
+			return problems[i].Entity < problems[j].Entity
+		}
+		return problems[i].Field < problems[j].Field
+	})
+}
+
+// Validate fetches the sites/machines.json and sites/switches.json output
+// formats and returns the problems found by ValidateMachines and
+// ValidateSwitches.
+func (c Client) Validate() ([]Problem, error) {
+	return c.ValidateContext(context.Background())
+}
+
+// ValidateContext is like Validate, with a context for the requests.
+func (c Client) ValidateContext(ctx context.Context) ([]Problem, error) {
+	b, err := c.getContent(ctx, c.makeBaseURL()+"sites/machines.json")
+	if err != nil {
+		return nil, err
+	}
+	_, problems, err := ValidateMachines(b)
+	if err != nil {
+		return nil, err
+	}
+	b, err = c.getContent(ctx, c.makeBaseURL()+"sites/switches.json")
+	if err != nil {
+		return nil, err
+	}
+	_, switchProblems, err := ValidateSwitches(b)
+	if err != nil {
+		return nil, err
+	}
+	return append(problems, switchProblems...), nil
+}
@@ -0,0 +1,126 @@
+package siteinfo
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/m-lab/go/rtx"
+)
+
+func TestValidateMachines(t *testing.T) {
+	b, err := os.ReadFile("testdata/machines.json")
+	rtx.Must(err, "Could not read machines")
+	machines, problems, err := ValidateMachines(b)
+	rtx.Must(err, "Could not validate testdata")
+	if len(machines) == 0 || len(problems) != 0 {
+		t.Errorf("ValidateMachines(testdata) = %d machines, problems %v", len(machines), problems)
+	}
+
+	_, problems, err = ValidateMachines([]byte(`[
+		{"hostname": "mlab1-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.1", "ipv6": "2001:db8::1", "project": "mlab-staging", "type": "physical"},
+		{"hostname": "mlab1-lga01.mlab-oti.measurement-lab.org", "ipv4": "192.168.0.1", "project": "mlab-oti", "type": "physical"},
+		{"hostname": "not-a-machine", "ipv4": "2001:db8::2", "ipv6": "192.168.0.2", "project": "", "type": ""},
+		{"hostname": "mlab2-lga01.mlab-oti.measurement-lab.org", "ipv6": "bad", "project": "mlab-oti", "type": "virtual"},
+		{"hostname": "lga3356-c0a80001.mlab.autojoin.measurement-lab.org", "ipv4": "192.168.0.3", "project": "mlab-autojoin", "type": "virtual"},
+		{"hostname": "lga3356-c0a80002.mlab.sandbox.measurement-lab.org", "ipv4": "192.168.0.4", "project": "sandbox", "type": "virtual"}
+	]`))
+	rtx.Must(err, "Could not decode machines")
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	want := []string{
+		`sites/machines.json: lga3356-c0a80002.mlab.sandbox.measurement-lab.org: project: "sandbox" does not match the hostname project "mlab-sandbox"`,
+		`sites/machines.json: mlab1-lga01.mlab-oti.measurement-lab.org: hostname: duplicate hostname`,
+		`sites/machines.json: mlab1-lga01.mlab-oti.measurement-lab.org: ipv4: 192.168.0.1 is also used by mlab1-lga01.mlab-oti.measurement-lab.org`,
+		`sites/machines.json: mlab1-lga01.mlab-oti.measurement-lab.org: project: "mlab-staging" does not match the hostname project "mlab-oti"`,
+		`sites/machines.json: mlab2-lga01.mlab-oti.measurement-lab.org: ipv4: missing`,
+		`sites/machines.json: mlab2-lga01.mlab-oti.measurement-lab.org: ipv6: ParseAddr("bad"): unable to parse IP`,
+		`sites/machines.json: not-a-machine: hostname: invalid hostname: not-a-machine`,
+		`sites/machines.json: not-a-machine: ipv4: "2001:db8::2" is not an ipv4 address`,
+		`sites/machines.json: not-a-machine: ipv6: "192.168.0.2" is not an ipv6 address`,
+		`sites/machines.json: not-a-machine: project: missing`,
+		`sites/machines.json: not-a-machine: type: missing`,
+	}
+	if diff := deep.Equal(got, want); diff != nil {
+		t.Errorf("ValidateMachines() problems differ: %v", diff)
+	}
+
+	for _, bad := range []string{
+		`{"hostname": "mlab1-lga01"}`,
+		`[{"hostname": "mlab1-lga01.mlab-oti.measurement-lab.org", "ip4": "192.168.0.1"}]`,
+		`[] []`,
+	} {
+		if _, _, err := ValidateMachines([]byte(bad)); err == nil {
+			t.Errorf("ValidateMachines(%s) should fail", bad)
+		}
+	}
+}
+
+func TestValidateSwitches(t *testing.T) {
+	b, err := os.ReadFile("testdata/switches.json")
+	rtx.Must(err, "Could not read switches")
+	switches, problems, err := ValidateSwitches(b)
+	rtx.Must(err, "Could not validate testdata")
+	if len(switches) == 0 || len(problems) != 0 {
+		t.Errorf("ValidateSwitches(testdata) = %d switches, problems %v", len(switches), problems)
+	}
+
+	_, problems, err = ValidateSwitches([]byte(`{
+		"lga01": {"ipv4_prefix": "192.168.0.0/26"},
+		"LGA02": {"ipv4_prefix": "2001:db8::/64"},
+		"lga03": {"ipv4_prefix": ""}
+	}`))
+	rtx.Must(err, "Could not decode switches")
+	if len(problems) != 3 {
+		t.Fatalf("ValidateSwitches() = %v, want 3 problems", problems)
+	}
+	for i, want := range []struct{ entity, field string }{
+		{"LGA02", "ipv4_prefix"}, {"LGA02", "site"}, {"lga03", "ipv4_prefix"},
+	} {
+		if problems[i].Entity != want.entity || problems[i].Field != want.field {
+			t.Errorf("ValidateSwitches() problem %d = %v, want %s %s", i, problems[i], want.entity, want.field)
+		}
+	}
+
+	for _, bad := range []string{
+		`[]`,
+		`{"lga01": {"ipv4_prefix": "192.168.0.0/26", "auto_negotation": "yes"}}`,
+	} {
+		if _, _, err := ValidateSwitches([]byte(bad)); err == nil {
+			t.Errorf("ValidateSwitches(%s) should fail", bad)
+		}
+	}
+}
+
+func TestClient_Validate(t *testing.T) {
+	c, err := NewFromURL(context.Background(), newFixtureDir(t))
+	rtx.Must(err, "Could not create client")
+	problems, err := c.Validate()
+	rtx.Must(err, "Could not validate testdata")
+	if len(problems) != 0 {
+		t.Errorf("Validate() = %v, want no problems", problems)
+	}
+
+	dir := t.TempDir()
+	rtx.Must(os.Mkdir(dir+"/sites", 0755), "Could not create dir")
+	c, err = NewFromURL(context.Background(), dir)
+	rtx.Must(err, "Could not create client")
+	if _, err := c.Validate(); err == nil {
+		t.Error("Validate() should fail without machines")
+	}
+	rtx.Must(os.WriteFile(dir+"/sites/machines.json", []byte(`{}`), 0644), "Could not write machines")
+	if _, err := c.Validate(); err == nil {
+		t.Error("Validate() should fail with bad machines")
+	}
+	rtx.Must(os.WriteFile(dir+"/sites/machines.json", []byte(`[]`), 0644), "Could not write machines")
+	if _, err := c.Validate(); err == nil {
+		t.Error("Validate() should fail without switches")
+	}
+	rtx.Must(os.WriteFile(dir+"/sites/switches.json", []byte(`[]`), 0644), "Could not write switches")
+	if _, err := c.Validate(); err == nil {
+		t.Error("Validate() should fail with bad switches")
+	}
+}